@@ -0,0 +1,257 @@
+package gocaptcha
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailuresPerLevel 每累计多少次失败提升一级难度
+	DefaultFailuresPerLevel = 2
+	// DefaultRequestsPerLevel 每个 DefaultRateWindow 内每累计多少次请求提升一级难度
+	DefaultRequestsPerLevel = 20
+	// DefaultRateWindow 统计请求频率的时间窗口
+	DefaultRateWindow = time.Minute
+	// DefaultFailureDecay 失败记录的保留时间，超过后难度逐级回落
+	DefaultFailureDecay = 15 * time.Minute
+)
+
+// Counter 按 key 统计滑动时间窗口内事件次数的计数器，可替换为 Redis 等外部实现
+type Counter interface {
+	// Add 记录一次事件，返回最近 window 内的事件次数
+	Add(key string, window time.Duration) (int, error)
+	// Count 返回最近 window 内的事件次数
+	Count(key string, window time.Duration) (int, error)
+	// Reset 清除 key 的全部记录
+	Reset(key string) error
+}
+
+// maxCounterEvents 内存计数器每个 key 最多保留的事件数，超出后丢弃最早的事件，计数不再增长
+const maxCounterEvents = 1024
+
+// memoryCounterSweep 内存计数器清理过期 key 的间隔
+const memoryCounterSweep = time.Minute
+
+// counterEvents 一个 key 的事件记录，只保留最近 window 内的事件
+type counterEvents struct {
+	times  []time.Time
+	window time.Duration
+}
+
+type memoryCounter struct {
+	mu        sync.Mutex
+	events    map[string]*counterEvents
+	lastSweep time.Time
+}
+
+// Add 记录一次事件，并顺带清理已过期的 key.
+// 每个 key 只保留最近一次 Add 时的 window 内的事件，最多 maxCounterEvents 个.
+func (c *memoryCounter) Add(key string, window time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > memoryCounterSweep {
+		for k, e := range c.events {
+			if len(e.times) == 0 || now.Sub(e.times[len(e.times)-1]) > e.window {
+				delete(c.events, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	e := c.events[key]
+	if e == nil {
+		e = &counterEvents{}
+		c.events[key] = e
+	}
+	e.window = window
+	times := e.times[countBefore(e.times, now.Add(-window)):]
+	if len(times) >= maxCounterEvents {
+		times = times[len(times)-maxCounterEvents+1:]
+	}
+	e.times = append(times, now)
+	return len(e.times), nil
+}
+
+// Count 返回最近 window 内的事件次数，超过该 key 保留的窗口的部分不计入
+func (c *memoryCounter) Count(key string, window time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.events[key]
+	if e == nil {
+		return 0, nil
+	}
+	return len(e.times) - countBefore(e.times, time.Now().Add(-window)), nil
+}
+
+// Reset 清除 key 的全部记录
+func (c *memoryCounter) Reset(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.events, key)
+	return nil
+}
+
+// countBefore 返回有序事件中早于 t 的事件个数
+func countBefore(events []time.Time, t time.Time) int {
+	return sort.Search(len(events), func(i int) bool { return !events[i].Before(t) })
+}
+
+// NewMemoryCounter 创建一个内存计数器
+func NewMemoryCounter() Counter {
+	return &memoryCounter{
+		events:    make(map[string]*counterEvents),
+		lastSweep: time.Now(),
+	}
+}
+
+// ChallengeLevel 难度等级，Challenge 不为空时表示改用其他类型的验证码
+type ChallengeLevel struct {
+	// Difficulty 验证码难度
+	Difficulty CaptchaDifficulty
+	// Challenge 替代的验证码类型，例如 ChallengeOddGlyph、ChallengePoW，为空时使用文本验证码
+	Challenge string
+}
+
+// DefaultChallengeLevels 默认的升级路径：文本验证码从非常简单到困难，之后改用找不同和工作量证明
+var DefaultChallengeLevels = []ChallengeLevel{
+	{Difficulty: CaptchaVeryEasy},
+	{Difficulty: CaptchaEasy},
+	{Difficulty: CaptchaMedium},
+	{Difficulty: CaptchaHard},
+	{Difficulty: CaptchaHard, Challenge: ChallengeOddGlyph},
+	{Difficulty: CaptchaHard, Challenge: ChallengePoW},
+}
+
+// AdaptivePolicy 按客户端（IP、会话、账号等 key）的失败次数和请求频率自动调整验证码难度.
+// 每条失败记录在 FailureDecay 之后过期，因此停止失败后难度会逐级回落.
+type AdaptivePolicy struct {
+	// Counter 计数器，为空时使用内存计数器
+	Counter Counter
+	// Levels 升级路径，为空时使用 DefaultChallengeLevels
+	Levels []ChallengeLevel
+	// FailuresPerLevel 每累计多少次失败提升一级
+	FailuresPerLevel int
+	// RequestsPerLevel 每个 RateWindow 内每累计多少次请求提升一级
+	RequestsPerLevel int
+	// RateWindow 请求频率的统计窗口
+	RateWindow time.Duration
+	// FailureDecay 失败记录的保留时间
+	FailureDecay time.Duration
+	// Verifier 生成和校验各等级的题目，为空时使用 DefaultStore 和 DefaultChallengeRegistry
+	Verifier *Verifier
+
+	once sync.Once
+}
+
+// NewAdaptivePolicy 使用默认参数创建策略，counter 为空时使用内存计数器
+func NewAdaptivePolicy(counter Counter) *AdaptivePolicy {
+	return &AdaptivePolicy{Counter: counter}
+}
+
+// init 填充未设置的参数
+func (p *AdaptivePolicy) init() {
+	p.once.Do(func() {
+		if p.Counter == nil {
+			p.Counter = NewMemoryCounter()
+		}
+		if len(p.Levels) == 0 {
+			p.Levels = DefaultChallengeLevels
+		}
+		if p.FailuresPerLevel <= 0 {
+			p.FailuresPerLevel = DefaultFailuresPerLevel
+		}
+		if p.RequestsPerLevel <= 0 {
+			p.RequestsPerLevel = DefaultRequestsPerLevel
+		}
+		if p.RateWindow <= 0 {
+			p.RateWindow = DefaultRateWindow
+		}
+		if p.FailureDecay <= 0 {
+			p.FailureDecay = DefaultFailureDecay
+		}
+	})
+}
+
+// Level 返回 key 当前的难度等级
+func (p *AdaptivePolicy) Level(key string) (ChallengeLevel, error) {
+	p.init()
+	failures, err := p.Counter.Count(failureKey(key), p.FailureDecay)
+	if err != nil {
+		return ChallengeLevel{}, err
+	}
+	requests, err := p.Counter.Count(requestKey(key), p.RateWindow)
+	if err != nil {
+		return ChallengeLevel{}, err
+	}
+	i := failures/p.FailuresPerLevel + requests/p.RequestsPerLevel
+	if i >= len(p.Levels) {
+		i = len(p.Levels) - 1
+	}
+	return p.Levels[i], nil
+}
+
+// Next 记录一次验证码请求，并返回本次应使用的难度等级
+func (p *AdaptivePolicy) Next(key string) (ChallengeLevel, error) {
+	p.init()
+	if _, err := p.Counter.Add(requestKey(key), p.RateWindow); err != nil {
+		return ChallengeLevel{}, err
+	}
+	return p.Level(key)
+}
+
+// Fail 记录一次校验失败
+func (p *AdaptivePolicy) Fail(key string) error {
+	p.init()
+	_, err := p.Counter.Add(failureKey(key), p.FailureDecay)
+	return err
+}
+
+func (p *AdaptivePolicy) verifier() *Verifier {
+	if p.Verifier == nil {
+		return &Verifier{}
+	}
+	return p.Verifier
+}
+
+// Issue 记录一次请求，并按 key 当前的等级生成题目.
+// opts.Difficulty 会被等级的难度覆盖，Challenge 为空的等级使用 ChallengeText.
+func (p *AdaptivePolicy) Issue(key string, opts ChallengeOptions) (*Challenge, error) {
+	level, err := p.Next(key)
+	if err != nil {
+		return nil, err
+	}
+	typeName := level.Challenge
+	if typeName == "" {
+		typeName = ChallengeText
+	}
+	opts.Difficulty = level.Difficulty
+	return p.verifier().IssueChallenge(key, typeName, opts)
+}
+
+// Verify 通过 Verifier 按题目类型校验 Issue 生成的题目，正确时返回 nil.
+// 答错、提交过快或题目不存在时为 key 记录一次失败.
+func (p *AdaptivePolicy) Verify(key string, id string, answer string) error {
+	err := p.verifier().Verify(key, id, answer)
+	if errors.Is(err, ErrWrongAnswer) || errors.Is(err, ErrTooManyAttempts) ||
+		errors.Is(err, ErrTooFast) || errors.Is(err, ErrCaptchaNotFound) {
+		_ = p.Fail(key)
+	}
+	return err
+}
+
+// Reset 清除 key 的全部记录，例如用户登录成功后
+func (p *AdaptivePolicy) Reset(key string) error {
+	p.init()
+	if err := p.Counter.Reset(failureKey(key)); err != nil {
+		return err
+	}
+	return p.Counter.Reset(requestKey(key))
+}
+
+func failureKey(key string) string { return "fail:" + key }
+func requestKey(key string) string { return "req:" + key }
@@ -0,0 +1,164 @@
+package gocaptcha
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCounter(t *testing.T) {
+	c := NewMemoryCounter()
+	for i := 1; i <= 3; i++ {
+		if n, err := c.Add("k", time.Minute); err != nil || n != i {
+			t.Fatalf("Add() = %v, %v, want %v", n, err, i)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	c.Add("k", time.Minute)
+	if n, _ := c.Count("k", 20*time.Millisecond); n != 1 {
+		t.Errorf("Count() in short window = %v, want 1", n)
+	}
+	if n, _ := c.Count("k", time.Minute); n != 4 {
+		t.Errorf("Count() = %v, want 4", n)
+	}
+	if n, _ := c.Count("other", time.Minute); n != 0 {
+		t.Errorf("Count() of unknown key = %v, want 0", n)
+	}
+	c.Reset("k")
+	if n, _ := c.Count("k", time.Minute); n != 0 {
+		t.Errorf("Count() after Reset = %v, want 0", n)
+	}
+}
+
+func TestAdaptivePolicyEscalation(t *testing.T) {
+	p := &AdaptivePolicy{FailuresPerLevel: 1}
+	for i, want := range DefaultChallengeLevels {
+		level, err := p.Next("client")
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if level != want {
+			t.Errorf("after %d failures: level = %+v, want %+v", i, level, want)
+		}
+		p.Fail("client")
+	}
+	// 超过最高等级后停留在最后一级
+	if level, _ := p.Level("client"); level != DefaultChallengeLevels[len(DefaultChallengeLevels)-1] {
+		t.Errorf("Level() = %+v, want last level", level)
+	}
+	// 其他客户端不受影响
+	if level, _ := p.Level("other"); level != DefaultChallengeLevels[0] {
+		t.Errorf("Level(other) = %+v, want first level", level)
+	}
+	p.Reset("client")
+	if level, _ := p.Level("client"); level != DefaultChallengeLevels[0] {
+		t.Errorf("Level() after Reset = %+v, want first level", level)
+	}
+}
+
+func TestAdaptivePolicyDecay(t *testing.T) {
+	p := &AdaptivePolicy{FailuresPerLevel: 1, FailureDecay: 100 * time.Millisecond}
+	p.Fail("client")
+	time.Sleep(60 * time.Millisecond)
+	p.Fail("client")
+	if level, _ := p.Level("client"); level.Difficulty != CaptchaMedium {
+		t.Fatalf("Level() = %+v, want medium", level)
+	}
+	// 第一次失败过期后回落一级，全部过期后回到最低级
+	time.Sleep(60 * time.Millisecond)
+	if level, _ := p.Level("client"); level.Difficulty != CaptchaEasy {
+		t.Errorf("Level() after first decay = %+v, want easy", level)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if level, _ := p.Level("client"); level.Difficulty != CaptchaVeryEasy {
+		t.Errorf("Level() after full decay = %+v, want very easy", level)
+	}
+}
+
+func TestAdaptivePolicyRequestRate(t *testing.T) {
+	p := &AdaptivePolicy{RequestsPerLevel: 3}
+	var level ChallengeLevel
+	for i := 0; i < 7; i++ {
+		level, _ = p.Next("burst")
+	}
+	if level.Difficulty != CaptchaMedium {
+		t.Errorf("Level() after 7 requests = %+v, want medium", level)
+	}
+}
+
+func TestMemoryCounterBounded(t *testing.T) {
+	c := NewMemoryCounter().(*memoryCounter)
+	for i := 0; i < maxCounterEvents+10; i++ {
+		c.Add("k", time.Hour)
+	}
+	if n, _ := c.Count("k", time.Hour); n != maxCounterEvents {
+		t.Errorf("Count() = %v, want %v", n, maxCounterEvents)
+	}
+	// 每个 key 只保留自己窗口内的事件
+	c.Add("short", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if n, _ := c.Add("short", 10*time.Millisecond); n != 1 {
+		t.Errorf("Add() = %v, want 1", n)
+	}
+	if n := len(c.events["short"].times); n != 1 {
+		t.Errorf("kept %d events, want 1", n)
+	}
+}
+
+func TestAdaptivePolicyVerify(t *testing.T) {
+	p := &AdaptivePolicy{FailuresPerLevel: 1, Verifier: &Verifier{Store: NewMemoryStore(DefaultExpiration)}}
+	c, err := p.Issue("client", ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if c.Type != ChallengeText {
+		t.Errorf("Issue() type = %q, want %q", c.Type, ChallengeText)
+	}
+	if err = p.Verify("client", c.ID, "wrong"); !errors.Is(err, ErrWrongAnswer) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrWrongAnswer)
+	}
+	if level, _ := p.Level("client"); level.Difficulty != CaptchaEasy {
+		t.Errorf("Level() after failed Verify = %+v, want easy", level)
+	}
+	c, _ = p.Issue("client", ChallengeOptions{})
+	if err = p.Verify("client", c.ID, strings.ToUpper(c.record)); err != nil {
+		t.Errorf("Verify() error = %v for the right answer", err)
+	}
+}
+
+func TestAdaptivePolicyVerifyEscalatedLevels(t *testing.T) {
+	p := &AdaptivePolicy{
+		FailuresPerLevel: 1,
+		Levels: []ChallengeLevel{
+			{Difficulty: CaptchaHard, Challenge: ChallengeOddGlyph},
+			{Difficulty: CaptchaVeryEasy, Challenge: ChallengePoW},
+		},
+		Verifier: &Verifier{Store: NewMemoryStore(DefaultExpiration)},
+	}
+	c, err := p.Issue("client", ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if c.Type != ChallengeOddGlyph {
+		t.Fatalf("Issue() type = %q, want %q", c.Type, ChallengeOddGlyph)
+	}
+	// 找不同的答案是点击位置，答错同样计入失败
+	if err = p.Verify("client", c.ID, "-1,-1"); !errors.Is(err, ErrWrongAnswer) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrWrongAnswer)
+	}
+
+	c, err = p.Issue("client", ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if c.Type != ChallengePoW {
+		t.Fatalf("Issue() type = %q, want %q", c.Type, ChallengePoW)
+	}
+	bits, _ := strconv.Atoi(c.Payload.Metadata["bits"])
+	pow := &PoWChallenge{Prefix: c.Payload.Metadata["prefix"], Bits: bits}
+	if err = p.Verify("client", c.ID, pow.Solve()); err != nil {
+		t.Errorf("Verify() error = %v for a solved proof of work", err)
+	}
+}
@@ -0,0 +1,181 @@
+package gocaptcha
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed words/en.txt
+var embeddedWords []byte
+
+var ErrNoWords = errors.New("no words of the requested length")
+
+// DefaultWordList 内置的常用英文单词表
+var DefaultWordList = loadWordList(embeddedWords)
+
+// DefaultAnswerGenerator 默认的答案生成器，从 TextCharacters 中随机选取字符并过滤敏感词
+var DefaultAnswerGenerator = NewFilteredGenerator(NewCharsetGenerator(nil), DefaultProfanityFilter)
+
+// AnswerGenerator 验证码答案生成器
+type AnswerGenerator interface {
+	// Generate 生成长度为 length 的答案
+	Generate(length int) (string, error)
+	// Entropy 返回长度为 length 的答案空间的熵，单位为比特
+	Entropy(length int) float64
+}
+
+type charsetGenerator struct {
+	characters []rune
+}
+
+// Generate 从字符集中随机选取 length 个字符
+func (g *charsetGenerator) Generate(length int) (string, error) {
+	characters := g.charset()
+	text := make([]rune, length)
+	for i := range text {
+		text[i] = characters[rand.Intn(len(characters))]
+	}
+	return string(text), nil
+}
+
+// Entropy 返回 length * log2(字符集大小)
+func (g *charsetGenerator) Entropy(length int) float64 {
+	return float64(length) * math.Log2(float64(len(g.charset())))
+}
+
+func (g *charsetGenerator) charset() []rune {
+	if len(g.characters) == 0 {
+		return TextCharacters
+	}
+	return g.characters
+}
+
+// NewCharsetGenerator 返回从 characters 中随机选取字符的生成器，characters 为空时使用 TextCharacters
+func NewCharsetGenerator(characters []rune) AnswerGenerator {
+	return &charsetGenerator{characters: characters}
+}
+
+var (
+	// pronounceableConsonants 易于辨认的辅音字母
+	pronounceableConsonants = []rune("bcdfghjkmnprstvwz")
+	// pronounceableVowels 易于辨认的元音字母
+	pronounceableVowels = []rune("aeiu")
+)
+
+type pronounceableGenerator struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// Generate 生成辅音、元音交替出现的字符串，如 "bakudo"
+func (g *pronounceableGenerator) Generate(length int) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	text := make([]rune, length)
+	for i := range text {
+		if i%2 == 0 {
+			text[i] = pronounceableConsonants[g.r.Intn(len(pronounceableConsonants))]
+		} else {
+			text[i] = pronounceableVowels[g.r.Intn(len(pronounceableVowels))]
+		}
+	}
+	return string(text), nil
+}
+
+// Entropy 返回辅音位与元音位的熵之和
+func (g *pronounceableGenerator) Entropy(length int) float64 {
+	consonants := (length + 1) / 2
+	vowels := length / 2
+	return float64(consonants)*math.Log2(float64(len(pronounceableConsonants))) +
+		float64(vowels)*math.Log2(float64(len(pronounceableVowels)))
+}
+
+// NewPronounceableGenerator 返回生成可拼读字符串的生成器，适合在手机上输入
+func NewPronounceableGenerator() AnswerGenerator {
+	return &pronounceableGenerator{
+		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+type wordListGenerator struct {
+	mu sync.Mutex
+	r  *rand.Rand
+	// byLength 按字符数分组的单词
+	byLength map[int][]string
+}
+
+// Generate 随机返回一个长度为 length 的单词
+func (g *wordListGenerator) Generate(length int) (string, error) {
+	words := g.byLength[length]
+	if len(words) == 0 {
+		return "", ErrNoWords
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return words[g.r.Intn(len(words))], nil
+}
+
+// Entropy 返回 log2(长度为 length 的单词数量)
+func (g *wordListGenerator) Entropy(length int) float64 {
+	if len(g.byLength[length]) == 0 {
+		return 0
+	}
+	return math.Log2(float64(len(g.byLength[length])))
+}
+
+// NewWordListGenerator 返回从单词表中选词的生成器.
+// words 为空时使用 DefaultWordList，命中 DefaultProfanityFilter 或包含 blocklist 中任一子串的单词会被排除（不区分大小写）.
+func NewWordListGenerator(words []string, blocklist ...string) AnswerGenerator {
+	if len(words) == 0 {
+		words = DefaultWordList
+	}
+	g := &wordListGenerator{
+		r:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		byLength: make(map[int][]string),
+	}
+	seen := make(map[string]bool)
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word == "" || seen[word] || containsAny(word, blocklist) || DefaultProfanityFilter.Contains(word) {
+			continue
+		}
+		seen[word] = true
+		n := utf8.RuneCountInString(word)
+		g.byLength[n] = append(g.byLength[n], word)
+	}
+	return g
+}
+
+// containsAny 判断 s 是否包含 substrings 中的任一子串，不区分大小写
+func containsAny(s string, substrings []string) bool {
+	s = strings.ToLower(s)
+	for _, sub := range substrings {
+		if sub != "" && strings.Contains(s, strings.ToLower(sub)) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWordList 按行解析单词表，忽略空行和 # 开头的注释
+func loadWordList(data []byte) []string {
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words
+}
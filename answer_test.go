@@ -0,0 +1,144 @@
+package gocaptcha
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAnswerGenerator_Generate(t *testing.T) {
+	tests := []struct {
+		name    string
+		g       AnswerGenerator
+		length  int
+		allowed string
+		wantErr bool
+	}{
+		{
+			name:    "default charset",
+			g:       DefaultAnswerGenerator,
+			length:  4,
+			allowed: string(TextCharacters),
+		},
+		{
+			name:    "custom charset",
+			g:       NewCharsetGenerator([]rune("0123456789")),
+			length:  6,
+			allowed: "0123456789",
+		},
+		{
+			name:    "pronounceable",
+			g:       NewPronounceableGenerator(),
+			length:  5,
+			allowed: string(pronounceableConsonants) + string(pronounceableVowels),
+		},
+		{
+			name:    "word list",
+			g:       NewWordListGenerator(nil),
+			length:  5,
+			allowed: "abcdefghijklmnopqrstuvwxyz",
+		},
+		{
+			name:    "word list without matching length",
+			g:       NewWordListGenerator([]string{"cat", "dog"}),
+			length:  5,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.g.Generate(tt.length)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if utf8.RuneCountInString(got) != tt.length {
+				t.Errorf("Generate() = %v, want length %v", got, tt.length)
+			}
+			for _, s := range got {
+				if !strings.ContainsRune(tt.allowed, s) {
+					t.Errorf("Generate() = %v, contains unexpected %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestPronounceableGenerator_Generate(t *testing.T) {
+	got, _ := NewPronounceableGenerator().Generate(6)
+	for i, s := range got {
+		isVowel := strings.ContainsRune(string(pronounceableVowels), s)
+		if isVowel != (i%2 == 1) {
+			t.Errorf("Generate() = %v, letter %d breaks consonant-vowel pattern", got, i)
+		}
+	}
+}
+
+func TestAnswerGenerator_Entropy(t *testing.T) {
+	tests := []struct {
+		name   string
+		g      AnswerGenerator
+		length int
+		want   float64
+	}{
+		{
+			name:   "charset",
+			g:      NewCharsetGenerator([]rune("01")),
+			length: 8,
+			want:   8,
+		},
+		{
+			name:   "pronounceable",
+			g:      NewPronounceableGenerator(),
+			length: 4,
+			want:   2*math.Log2(float64(len(pronounceableConsonants))) + 2*math.Log2(float64(len(pronounceableVowels))),
+		},
+		{
+			name:   "word list",
+			g:      NewWordListGenerator([]string{"bear", "fish", "frog", "lion"}),
+			length: 4,
+			want:   2,
+		},
+		{
+			name:   "word list without matching length",
+			g:      NewWordListGenerator([]string{"bear"}),
+			length: 5,
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.g.Entropy(tt.length); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Entropy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWordListGenerator_Blocklist(t *testing.T) {
+	g := NewWordListGenerator([]string{"bear", "Damn", "fish", "fish"}, "damn")
+	if got := g.Entropy(4); got != 1 {
+		t.Errorf("Entropy() = %v, want 1", got)
+	}
+	for i := 0; i < 20; i++ {
+		if got, _ := g.Generate(4); got == "Damn" {
+			t.Errorf("Generate() = %v, blocked word", got)
+		}
+	}
+}
+
+func TestGenerateCaptchaWithGenerator(t *testing.T) {
+	text, imgBytes, err := GenerateCaptchaWithGenerator(180, 60, 5, CaptchaMedium, NewWordListGenerator(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(text) != 5 || len(imgBytes) == 0 {
+		t.Errorf("GenerateCaptchaWithGenerator() text = %v, len(imgBytes) = %d", text, len(imgBytes))
+	}
+	if _, _, err = GenerateCaptchaWithGenerator(180, 60, 20, CaptchaMedium, NewWordListGenerator(nil)); err == nil {
+		t.Errorf("GenerateCaptchaWithGenerator() should fail without words of length 20")
+	}
+}
@@ -1,6 +1,8 @@
 package gocaptcha
 
 import (
+	"context"
+	"image"
 	"image/color"
 	"image/draw"
 	"math"
@@ -10,75 +12,346 @@ type BlurDrawer interface {
 	DrawBlur(canvas draw.Image, kernelSize int, sigma float64) error
 }
 
+// ContextBlurDrawer is implemented by blur drawers that check ctx between passes.
+type ContextBlurDrawer interface {
+	DrawBlurContext(ctx context.Context, canvas draw.Image, kernelSize int, sigma float64) error
+}
+
+// drawBlur calls DrawBlurContext when drawer supports it, otherwise DrawBlur.
+func drawBlur(ctx context.Context, drawer BlurDrawer, canvas draw.Image, kernelSize int, sigma float64) error {
+	if d, ok := drawer.(ContextBlurDrawer); ok {
+		return d.DrawBlurContext(ctx, canvas, kernelSize, sigma)
+	}
+	return drawer.DrawBlur(canvas, kernelSize, sigma)
+}
+
 type gaussianBlur struct {
 }
 
+// NewGaussianBlur returns a Gaussian blur drawer.
+// The kernel is applied as two separable one-dimensional passes on a separate buffer.
 func NewGaussianBlur() BlurDrawer {
 	return &gaussianBlur{}
 }
 
 func (g *gaussianBlur) DrawBlur(canvas draw.Image, kernelSize int, sigma float64) error {
-	kernel := g.generateGaussianKernel(kernelSize, sigma)
-	bounds := canvas.Bounds()
+	return g.DrawBlurContext(context.Background(), canvas, kernelSize, sigma)
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b := g.applyKernel(canvas, x, y, kernel)
-			canvas.Set(x, y, color.RGBA{R: r, G: g, B: b, A: 255})
-		}
+// DrawBlurContext blurs the canvas, returning ctx.Err() if ctx is done between passes.
+// The canvas is left unchanged when the blur is cancelled.
+func (g *gaussianBlur) DrawBlurContext(ctx context.Context, canvas draw.Image, kernelSize int, sigma float64) error {
+	if kernelSize < 1 || sigma <= 0 {
+		return nil
 	}
+	kernel := g.generateGaussianKernel(kernelSize, sigma)
+	p := loadPlanes(canvas)
+	defer p.release()
+	tmp := p.scratch()
+	defer tmp.release()
+	convolveRows(tmp.pix, p.pix, p.w, p.h, kernel)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	convolveColumns(p.pix, tmp.pix, p.w, p.h, kernel)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.store(canvas)
 	return nil
 }
 
-func (g *gaussianBlur) generateGaussianKernel(kernelSize int, sigma float64) [][]float64 {
-	kernel := make([][]float64, kernelSize)
+// generateGaussianKernel returns the normalized one-dimensional kernel.
+// The outer product of the kernel with itself equals the normalized two-dimensional kernel.
+func (g *gaussianBlur) generateGaussianKernel(kernelSize int, sigma float64) []float64 {
+	kernel := make([]float64, kernelSize)
 	sum := 0.0
 	mid := kernelSize / 2
+	for i := range kernel {
+		x := float64(i - mid)
+		kernel[i] = math.Exp(-(x * x) / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+type boxBlur struct {
+	passes int
+}
 
-	for i := 0; i < kernelSize; i++ {
-		kernel[i] = make([]float64, kernelSize)
-		for j := 0; j < kernelSize; j++ {
-			x := float64(i - mid)
-			y := float64(j - mid)
-			kernel[i][j] = math.Exp(-(x*x+y*y)/(2*sigma*sigma)) / (2 * math.Pi * sigma * sigma)
-			sum += kernel[i][j]
+// NewBoxGaussianBlur returns an approximate Gaussian blur drawer that applies passes box blurs
+// computed with running sums, so its cost does not depend on sigma.
+// kernelSize is ignored, the box sizes are derived from sigma. Three passes are usually
+// indistinguishable from a true Gaussian.
+func NewBoxGaussianBlur(passes int) BlurDrawer {
+	if passes < 1 {
+		passes = 3
+	}
+	return &boxBlur{passes: passes}
+}
+
+func (b *boxBlur) DrawBlur(canvas draw.Image, kernelSize int, sigma float64) error {
+	return b.DrawBlurContext(context.Background(), canvas, kernelSize, sigma)
+}
+
+// DrawBlurContext blurs the canvas, returning ctx.Err() if ctx is done between passes.
+// The canvas is left unchanged when the blur is cancelled.
+func (b *boxBlur) DrawBlurContext(ctx context.Context, canvas draw.Image, kernelSize int, sigma float64) error {
+	if sigma <= 0 {
+		return nil
+	}
+	p := loadPlanes(canvas)
+	defer p.release()
+	tmp := p.scratch()
+	defer tmp.release()
+	for _, size := range boxSizesForGauss(sigma, b.passes) {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		r := (size - 1) / 2
+		boxRows(tmp.pix, p.pix, p.w, p.h, r)
+		boxColumns(p.pix, tmp.pix, p.w, p.h, r)
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.store(canvas)
+	return nil
+}
 
-	// Normalize kernel
-	for i := 0; i < kernelSize; i++ {
-		for j := 0; j < kernelSize; j++ {
-			kernel[i][j] /= sum
+// boxSizesForGauss returns n odd box sizes whose successive application approximates
+// a Gaussian with the given sigma.
+func boxSizesForGauss(sigma float64, n int) []int {
+	wIdeal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+	wl := int(math.Floor(wIdeal))
+	if wl%2 == 0 {
+		wl--
+	}
+	wu := wl + 2
+	mIdeal := (12*sigma*sigma - float64(n*wl*wl) - float64(4*n*wl) - float64(3*n)) / float64(-4*wl-4)
+	m := int(math.Round(mIdeal))
+	sizes := make([]int, n)
+	for i := range sizes {
+		if i < m {
+			sizes[i] = wl
+		} else {
+			sizes[i] = wu
 		}
 	}
+	return sizes
+}
 
-	return kernel
+// planes holds the premultiplied red, green and blue channels of an image as
+// interleaved float64 values, three per pixel.
+type planes struct {
+	rect image.Rectangle
+	w, h int
+	pix  []float64
+}
+
+// loadPlanes copies the color channels of canvas, reading *image.NRGBA and *image.RGBA
+// directly from their Pix slices. The planes come from a pool, call release when done.
+func loadPlanes(canvas image.Image) *planes {
+	rect := canvas.Bounds()
+	w, h := rect.Dx(), rect.Dy()
+	p := planesPool.get(3 * w * h)
+	p.rect, p.w, p.h = rect, w, h
+	switch m := canvas.(type) {
+	case *image.NRGBA:
+		for y := 0; y < h; y++ {
+			row := m.Pix[m.PixOffset(rect.Min.X, rect.Min.Y+y):]
+			dst := p.pix[3*y*w:]
+			for x := 0; x < w; x++ {
+				s := row[4*x : 4*x+4 : 4*x+4]
+				a := uint32(s[3]) * 0x101
+				// premultiply the same way as color.NRGBA.RGBA
+				dst[3*x] = float64((uint32(s[0]) * 0x101 * a / 0xffff) >> 8)
+				dst[3*x+1] = float64((uint32(s[1]) * 0x101 * a / 0xffff) >> 8)
+				dst[3*x+2] = float64((uint32(s[2]) * 0x101 * a / 0xffff) >> 8)
+			}
+		}
+	case *image.RGBA:
+		for y := 0; y < h; y++ {
+			row := m.Pix[m.PixOffset(rect.Min.X, rect.Min.Y+y):]
+			dst := p.pix[3*y*w:]
+			for x := 0; x < w; x++ {
+				dst[3*x] = float64(row[4*x])
+				dst[3*x+1] = float64(row[4*x+1])
+				dst[3*x+2] = float64(row[4*x+2])
+			}
+		}
+	default:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				r, g, b, _ := canvas.At(rect.Min.X+x, rect.Min.Y+y).RGBA()
+				i := 3 * (y*w + x)
+				p.pix[i], p.pix[i+1], p.pix[i+2] = float64(r>>8), float64(g>>8), float64(b>>8)
+			}
+		}
+	}
+	return p
 }
 
-func (g *gaussianBlur) applyKernel(canvas draw.Image, x int, y int, kernel [][]float64) (uint8, uint8, uint8) {
-	bounds := canvas.Bounds()
-	size := len(kernel)
-	mid := size / 2
-	var r1, g1, b1 float64
-
-	for ky := 0; ky < size; ky++ {
-		for kx := 0; kx < size; kx++ {
-			px := x + kx - mid
-			py := y + ky - mid
-			if px >= bounds.Min.X && px < bounds.Max.X && py >= bounds.Min.Y && py < bounds.Max.Y {
-				rr, gg, bb, _ := canvas.At(px, py).RGBA()
-				k := kernel[ky][kx]
-				r1 += k * float64(rr>>8)
-				g1 += k * float64(gg>>8)
-				b1 += k * float64(bb>>8)
+// store writes the channels back to canvas as opaque pixels.
+func (p *planes) store(canvas draw.Image) {
+	var pix []uint8
+	var stride int
+	switch m := canvas.(type) {
+	case *image.NRGBA:
+		pix, stride = m.Pix[m.PixOffset(p.rect.Min.X, p.rect.Min.Y):], m.Stride
+	case *image.RGBA:
+		pix, stride = m.Pix[m.PixOffset(p.rect.Min.X, p.rect.Min.Y):], m.Stride
+	default:
+		for y := 0; y < p.h; y++ {
+			for x := 0; x < p.w; x++ {
+				i := 3 * (y*p.w + x)
+				canvas.Set(p.rect.Min.X+x, p.rect.Min.Y+y, color.RGBA{
+					R: clampUint8(p.pix[i]), G: clampUint8(p.pix[i+1]), B: clampUint8(p.pix[i+2]), A: 255,
+				})
 			}
 		}
+		return
 	}
+	// opaque pixels have the same representation in NRGBA and RGBA
+	for y := 0; y < p.h; y++ {
+		row := pix[y*stride:]
+		src := p.pix[3*y*p.w:]
+		for x := 0; x < p.w; x++ {
+			d := row[4*x : 4*x+4 : 4*x+4]
+			d[0] = clampUint8(src[3*x])
+			d[1] = clampUint8(src[3*x+1])
+			d[2] = clampUint8(src[3*x+2])
+			d[3] = 255
+		}
+	}
+}
+
+// convolveRows convolves every row of src with kernel into dst.
+// Samples outside the image are skipped, matching a direct two-dimensional convolution.
+func convolveRows(dst, src []float64, w, h int, kernel []float64) {
+	mid := len(kernel) / 2
+	parallelBands(h, minParallelRows, func(y0, y1 int) error {
+		for y := y0; y < y1; y++ {
+			row := src[3*y*w : 3*(y+1)*w]
+			out := dst[3*y*w : 3*(y+1)*w]
+			for x := 0; x < w; x++ {
+				var r, g, b float64
+				for k, kv := range kernel {
+					px := x + k - mid
+					if px < 0 || px >= w {
+						continue
+					}
+					r += kv * row[3*px]
+					g += kv * row[3*px+1]
+					b += kv * row[3*px+2]
+				}
+				out[3*x], out[3*x+1], out[3*x+2] = r, g, b
+			}
+		}
+		return nil
+	})
+}
+
+// convolveColumns convolves every column of src with kernel into dst.
+func convolveColumns(dst, src []float64, w, h int, kernel []float64) {
+	mid := len(kernel) / 2
+	parallelBands(h, minParallelRows, func(y0, y1 int) error {
+		for y := y0; y < y1; y++ {
+			out := dst[3*y*w : 3*(y+1)*w]
+			for i := range out {
+				out[i] = 0
+			}
+			for k, kv := range kernel {
+				py := y + k - mid
+				if py < 0 || py >= h {
+					continue
+				}
+				row := src[3*py*w : 3*(py+1)*w]
+				for i, v := range row {
+					out[i] += kv * v
+				}
+			}
+		}
+		return nil
+	})
+}
 
-	return g.clamp(r1), g.clamp(g1), g.clamp(b1)
+// boxRows applies a box blur of radius r to every row using a running sum.
+// The image edge is extended, so the average always covers 2r+1 samples.
+func boxRows(dst, src []float64, w, h int, r int) {
+	if w == 0 || h == 0 {
+		return
+	}
+	scale := 1 / float64(2*r+1)
+	clampX := func(x int) int {
+		if x < 0 {
+			return 0
+		}
+		if x >= w {
+			return w - 1
+		}
+		return x
+	}
+	parallelBands(h, minParallelRows, func(y0, y1 int) error {
+		for y := y0; y < y1; y++ {
+			row := src[3*y*w : 3*(y+1)*w]
+			out := dst[3*y*w : 3*(y+1)*w]
+			var sr, sg, sb float64
+			for x := -r; x <= r; x++ {
+				i := 3 * clampX(x)
+				sr, sg, sb = sr+row[i], sg+row[i+1], sb+row[i+2]
+			}
+			for x := 0; x < w; x++ {
+				out[3*x], out[3*x+1], out[3*x+2] = sr*scale, sg*scale, sb*scale
+				add, sub := 3*clampX(x+r+1), 3*clampX(x-r)
+				sr += row[add] - row[sub]
+				sg += row[add+1] - row[sub+1]
+				sb += row[add+2] - row[sub+2]
+			}
+		}
+		return nil
+	})
+}
+
+// boxColumns applies a box blur of radius r to every column using a running sum.
+// The running sum goes down the columns, so the work is split into bands of columns.
+func boxColumns(dst, src []float64, w, h int, r int) {
+	if w == 0 || h == 0 {
+		return
+	}
+	scale := 1 / float64(2*r+1)
+	stride := 3 * w
+	rowAt := func(y int) []float64 {
+		if y < 0 {
+			y = 0
+		} else if y >= h {
+			y = h - 1
+		}
+		return src[y*stride : (y+1)*stride]
+	}
+	parallelBands(stride, 3*minParallelRows, func(lo, hi int) error {
+		sum := make([]float64, hi-lo)
+		for y := -r; y <= r; y++ {
+			for i, v := range rowAt(y)[lo:hi] {
+				sum[i] += v
+			}
+		}
+		for y := 0; y < h; y++ {
+			out := dst[y*stride+lo : y*stride+hi]
+			add, sub := rowAt(y + r + 1)[lo:hi], rowAt(y - r)[lo:hi]
+			for i := range out {
+				out[i] = sum[i] * scale
+				sum[i] += add[i] - sub[i]
+			}
+		}
+		return nil
+	})
 }
 
-func (g *gaussianBlur) clamp(value float64) uint8 {
+func clampUint8(value float64) uint8 {
 	if value < 0 {
 		return 0
 	}
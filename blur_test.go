@@ -1,8 +1,12 @@
 package gocaptcha
 
 import (
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -28,6 +32,16 @@ func TestDrawBlur(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "box",
+			t:    NewBoxGaussianBlur(3),
+			args: args{
+				canvas:     image.NewNRGBA(image.Rect(0, 0, 100, 100)),
+				kernelSize: 5,
+				sigma:      2.0,
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -37,3 +51,219 @@ func TestDrawBlur(t *testing.T) {
 		})
 	}
 }
+
+// randomNRGBA returns an image of random pixels with partly transparent areas.
+func randomNRGBA(w, h int, seed int64) *image.NRGBA {
+	r := rand.New(rand.NewSource(seed))
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	r.Read(m.Pix)
+	for i := 3; i < len(m.Pix); i += 8 {
+		m.Pix[i] = 255
+	}
+	return m
+}
+
+// referenceBlur is a direct two-dimensional convolution with the normalized
+// Gaussian kernel, reading from an unmodified copy of the source.
+func referenceBlur(src image.Image, kernelSize int, sigma float64) *image.NRGBA {
+	kernel := make([][]float64, kernelSize)
+	sum := 0.0
+	mid := kernelSize / 2
+	for i := range kernel {
+		kernel[i] = make([]float64, kernelSize)
+		for j := range kernel[i] {
+			x, y := float64(i-mid), float64(j-mid)
+			kernel[i][j] = math.Exp(-(x*x+y*y)/(2*sigma*sigma)) / (2 * math.Pi * sigma * sigma)
+			sum += kernel[i][j]
+		}
+	}
+	b := src.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var r1, g1, b1 float64
+			for ky := 0; ky < kernelSize; ky++ {
+				for kx := 0; kx < kernelSize; kx++ {
+					px, py := x+kx-mid, y+ky-mid
+					if px >= b.Min.X && px < b.Max.X && py >= b.Min.Y && py < b.Max.Y {
+						rr, gg, bb, _ := src.At(px, py).RGBA()
+						k := kernel[ky][kx] / sum
+						r1 += k * float64(rr>>8)
+						g1 += k * float64(gg>>8)
+						b1 += k * float64(bb>>8)
+					}
+				}
+			}
+			dst.Set(x, y, color.RGBA{R: clampUint8(r1), G: clampUint8(g1), B: clampUint8(b1), A: 255})
+		}
+	}
+	return dst
+}
+
+// maxPixelDiff returns the largest channel difference between two images.
+func maxPixelDiff(a, b image.Image) (maxDiff int, meanDiff float64) {
+	bounds := a.Bounds()
+	var total, n float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, aa := a.At(x, y).RGBA()
+			br, bg, bb, ba := b.At(x, y).RGBA()
+			for _, d := range []int{int(ar>>8) - int(br>>8), int(ag>>8) - int(bg>>8), int(ab>>8) - int(bb>>8), int(aa>>8) - int(ba>>8)} {
+				if d < 0 {
+					d = -d
+				}
+				if d > maxDiff {
+					maxDiff = d
+				}
+				total += float64(d)
+				n++
+			}
+		}
+	}
+	return maxDiff, total / n
+}
+
+func TestGaussianBlurMatchesReference(t *testing.T) {
+	tests := []struct {
+		kernelSize int
+		sigma      float64
+	}{
+		{1, 0.3},
+		{2, 0.65},
+		{3, 1},
+		{5, 1.5},
+		{8, 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("k%d_s%v", tt.kernelSize, tt.sigma), func(t *testing.T) {
+			src := randomNRGBA(37, 23, int64(tt.kernelSize))
+			want := referenceBlur(src, tt.kernelSize, tt.sigma)
+
+			got := image.NewNRGBA(src.Bounds())
+			copy(got.Pix, src.Pix)
+			if err := NewGaussianBlur().DrawBlur(got, tt.kernelSize, tt.sigma); err != nil {
+				t.Fatal(err)
+			}
+			// 浮点运算顺序不同，截断后最多相差 1
+			if d, _ := maxPixelDiff(got, want); d > 1 {
+				t.Errorf("NRGBA: max difference from reference = %d", d)
+			}
+
+			rgba := image.NewRGBA(src.Bounds())
+			draw.Draw(rgba, rgba.Bounds(), src, image.Point{}, draw.Src)
+			NewGaussianBlur().DrawBlur(rgba, tt.kernelSize, tt.sigma)
+			if d, _ := maxPixelDiff(rgba, want); d > 1 {
+				t.Errorf("RGBA: max difference from reference = %d", d)
+			}
+
+			generic := image.NewRGBA64(src.Bounds())
+			draw.Draw(generic, generic.Bounds(), src, image.Point{}, draw.Src)
+			NewGaussianBlur().DrawBlur(generic, tt.kernelSize, tt.sigma)
+			if d, _ := maxPixelDiff(generic, want); d > 1 {
+				t.Errorf("generic: max difference from reference = %d", d)
+			}
+		})
+	}
+}
+
+func TestGaussianBlurSubImage(t *testing.T) {
+	src := randomNRGBA(40, 30, 7)
+	sub := src.SubImage(image.Rect(10, 5, 30, 25)).(*image.NRGBA)
+	want := referenceBlur(sub, 3, 1)
+	NewGaussianBlur().DrawBlur(sub, 3, 1)
+	if d, _ := maxPixelDiff(sub, want); d > 1 {
+		t.Errorf("max difference from reference = %d", d)
+	}
+	// 子图以外的像素不受影响
+	if orig := randomNRGBA(40, 30, 7); orig.NRGBAAt(5, 5) != src.NRGBAAt(5, 5) {
+		t.Error("pixel outside the sub image was modified")
+	}
+}
+
+func TestBoxGaussianBlurApproximatesGaussian(t *testing.T) {
+	// 平滑的渐变图像，避免边缘处理方式的差异
+	src := image.NewNRGBA(image.Rect(0, 0, 80, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 80; x++ {
+			v := uint8(128 + 100*math.Sin(float64(x)/6)*math.Cos(float64(y)/5))
+			src.SetNRGBA(x, y, color.NRGBA{R: v, G: 255 - v, B: uint8(x * 3), A: 255})
+		}
+	}
+	sigma := 2.0
+	want := referenceBlur(src, 13, sigma)
+	got := image.NewNRGBA(src.Bounds())
+	copy(got.Pix, src.Pix)
+	NewBoxGaussianBlur(3).DrawBlur(got, 0, sigma)
+
+	inner := image.Rect(8, 8, 72, 52)
+	_, mean := maxPixelDiff(got.SubImage(inner), want.SubImage(inner))
+	if mean > 1.5 {
+		t.Errorf("mean difference from Gaussian = %.2f", mean)
+	}
+}
+
+func TestBlurEmptyImage(t *testing.T) {
+	for _, size := range []image.Rectangle{image.Rect(0, 0, 0, 0), image.Rect(0, 0, 10, 0), image.Rect(0, 0, 0, 10)} {
+		m := image.NewNRGBA(size)
+		if err := NewBoxGaussianBlur(3).DrawBlur(m, 0, 2); err != nil {
+			t.Errorf("box blur of %v: %v", size, err)
+		}
+		if err := NewGaussianBlur().DrawBlur(m, 5, 1.5); err != nil {
+			t.Errorf("gaussian blur of %v: %v", size, err)
+		}
+	}
+}
+
+func TestBoxSizesForGauss(t *testing.T) {
+	for _, sigma := range []float64{0.5, 1, 2, 5} {
+		sizes := boxSizesForGauss(sigma, 3)
+		var variance float64
+		for _, s := range sizes {
+			if s%2 == 0 {
+				t.Errorf("sigma %v: even box size %d", sigma, s)
+			}
+			variance += float64(s*s-1) / 12
+		}
+		if got := math.Sqrt(variance); math.Abs(got-sigma) > 0.5 {
+			t.Errorf("sigma %v: boxes %v give sigma %.2f", sigma, sizes, got)
+		}
+	}
+}
+
+var blurBenchSizes = []image.Point{{180, 60}, {400, 150}}
+
+func BenchmarkGaussianBlur(b *testing.B) {
+	for _, size := range blurBenchSizes {
+		for _, kernelSize := range []int{DefaultBlurKernelSize, 5} {
+			src := randomNRGBA(size.X, size.Y, 1)
+			b.Run(fmt.Sprintf("reference/%dx%d/k%d", size.X, size.Y, kernelSize), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					referenceBlur(src, kernelSize, DefaultBlurSigma)
+				}
+			})
+			b.Run(fmt.Sprintf("separable/%dx%d/k%d", size.X, size.Y, kernelSize), func(b *testing.B) {
+				m := image.NewNRGBA(src.Bounds())
+				for i := 0; i < b.N; i++ {
+					copy(m.Pix, src.Pix)
+					NewGaussianBlur().DrawBlur(m, kernelSize, DefaultBlurSigma)
+				}
+			})
+		}
+		// 较大的 sigma 下比较精确模式与多次盒式模糊
+		src := randomNRGBA(size.X, size.Y, 1)
+		b.Run(fmt.Sprintf("separable/%dx%d/sigma4", size.X, size.Y), func(b *testing.B) {
+			m := image.NewNRGBA(src.Bounds())
+			for i := 0; i < b.N; i++ {
+				copy(m.Pix, src.Pix)
+				NewGaussianBlur().DrawBlur(m, 25, 4)
+			}
+		})
+		b.Run(fmt.Sprintf("box/%dx%d/sigma4", size.X, size.Y), func(b *testing.B) {
+			m := image.NewNRGBA(src.Bounds())
+			for i := 0; i < b.N; i++ {
+				copy(m.Pix, src.Pix)
+				NewBoxGaussianBlur(3).DrawBlur(m, 0, 4)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package gocaptcha
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"sync"
+)
+
+var ErrCaptchaReleased = errors.New("captcha image released")
+
+// sizedPool 按尺寸分组的对象池，同一个池中的对象尺寸相同.
+// 验证码的尺寸通常只有少数几种，每种尺寸一个 sync.Pool.
+type sizedPool[K comparable, T any] struct {
+	mu    sync.RWMutex
+	pools map[K]*sync.Pool
+	new   func(K) T
+}
+
+func newSizedPool[K comparable, T any](new func(K) T) *sizedPool[K, T] {
+	return &sizedPool[K, T]{pools: make(map[K]*sync.Pool), new: new}
+}
+
+func (p *sizedPool[K, T]) pool(k K) *sync.Pool {
+	p.mu.RLock()
+	sp := p.pools[k]
+	p.mu.RUnlock()
+	if sp != nil {
+		return sp
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sp = p.pools[k]; sp == nil {
+		sp = &sync.Pool{New: func() any { return p.new(k) }}
+		p.pools[k] = sp
+	}
+	return sp
+}
+
+// get 取出一个尺寸为 k 的对象，内容是上次使用留下的数据
+func (p *sizedPool[K, T]) get(k K) T {
+	if !bufferPooling {
+		return p.new(k)
+	}
+	return p.pool(k).Get().(T)
+}
+
+func (p *sizedPool[K, T]) put(k K, v T) {
+	if bufferPooling {
+		p.pool(k).Put(v)
+	}
+}
+
+var (
+	nrgbaPool = newSizedPool(func(size image.Point) *image.NRGBA {
+		return image.NewNRGBA(image.Rectangle{Max: size})
+	})
+	rgbaPool = newSizedPool(func(size image.Point) *image.RGBA {
+		return image.NewRGBA(image.Rectangle{Max: size})
+	})
+	planesPool = newSizedPool(func(n int) *planes {
+		return &planes{pix: make([]float64, n)}
+	})
+)
+
+// getNRGBA 从池中取出区域为 r 的画布，像素未清空
+func getNRGBA(r image.Rectangle) *image.NRGBA {
+	m := nrgbaPool.get(r.Size())
+	m.Rect = r
+	return m
+}
+
+// putNRGBA 将 getNRGBA 取出的画布放回池中，之后不能再使用 m
+func putNRGBA(m *image.NRGBA) {
+	nrgbaPool.put(m.Rect.Size(), m)
+}
+
+// getRGBA 从池中取出区域为 r 的透明图层
+func getRGBA(r image.Rectangle) *image.RGBA {
+	m := rgbaPool.get(r.Size())
+	m.Rect = r
+	clear(m.Pix)
+	return m
+}
+
+// putRGBA 将 getRGBA 取出的图层放回池中，之后不能再使用 m
+func putRGBA(m *image.RGBA) {
+	rgbaPool.put(m.Rect.Size(), m)
+}
+
+// scratch 返回与 p 尺寸相同的临时缓冲区，内容未初始化，使用完毕后调用 release
+func (p *planes) scratch() *planes {
+	s := planesPool.get(len(p.pix))
+	s.rect, s.w, s.h = p.rect, p.w, p.h
+	return s
+}
+
+// release 将 p 放回池中，之后不能再使用 p
+func (p *planes) release() {
+	planesPool.put(len(p.pix), p)
+}
+
+var encodeBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// pngBufferPool 复用 png 编码器的压缩缓冲区
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	if !bufferPooling {
+		return nil
+	}
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) {
+	if bufferPooling {
+		p.pool.Put(b)
+	}
+}
+
+var pngEncoder = png.Encoder{BufferPool: &pngBufferPool{}}
+
+// jpegWriters 复用 jpeg 编码使用的 bufio.Writer，jpeg.Encode 在 w 没有 Flush 方法时会新建一个
+var jpegWriters = sync.Pool{New: func() any { return bufio.NewWriter(nil) }}
+
+func getJPEGWriter(w io.Writer) *bufio.Writer {
+	if !bufferPooling {
+		return bufio.NewWriter(w)
+	}
+	bw := jpegWriters.Get().(*bufio.Writer)
+	bw.Reset(w)
+	return bw
+}
+
+func putJPEGWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	if bufferPooling {
+		jpegWriters.Put(bw)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build gocaptcha_nopool
+
+package gocaptcha
+
+// bufferPooling 为 false 时每次都重新分配缓冲区，仅用于与默认构建对比性能，例如
+//
+//	go test -tags gocaptcha_nopool -bench GenerateCaptchaParallel
+const bufferPooling = false
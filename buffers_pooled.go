@@ -0,0 +1,6 @@
+//go:build !gocaptcha_nopool
+
+package gocaptcha
+
+// bufferPooling 复用画布、模糊平面和编码缓冲区，使用 -tags gocaptcha_nopool 构建时关闭
+const bufferPooling = true
@@ -0,0 +1,154 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withFreshPools runs fn with empty buffer pools, so fn cannot see data left by
+// earlier images.
+func withFreshPools(fn func()) {
+	nrgba, rgba, planes := nrgbaPool, rgbaPool, planesPool
+	nrgbaPool, rgbaPool, planesPool = newSizedPool(nrgba.new), newSizedPool(rgba.new), newSizedPool(planes.new)
+	defer func() { nrgbaPool, rgbaPool, planesPool = nrgba, rgba, planes }()
+	fn()
+}
+
+func TestCaptchaImageRelease(t *testing.T) {
+	captcha := New(40, 20, color.RGBA{R: 255, A: 255})
+	captcha.Release()
+	if !errors.Is(captcha.Error, ErrCaptchaReleased) {
+		t.Errorf("Error = %v, want %v", captcha.Error, ErrCaptchaReleased)
+	}
+	// 释放后的绘制操作不再执行
+	captcha.DrawBorder(color.RGBA{A: 255}).DrawNoise(NoiseDensityLower, NewPointNoiseDrawer())
+	if err := captcha.Encode(new(bytes.Buffer), ImageFormatPng); !errors.Is(err, ErrCaptchaReleased) {
+		t.Errorf("Encode() error = %v, want %v", err, ErrCaptchaReleased)
+	}
+	captcha.Release()
+
+	failed := New(40, 20, color.RGBA{A: 255})
+	failed.Error = ErrNilText
+	failed.Release()
+	if !errors.Is(failed.Error, ErrNilText) {
+		t.Errorf("Error = %v, Release must keep the previous error", failed.Error)
+	}
+}
+
+func TestNewReusesCanvas(t *testing.T) {
+	// 放回一张被弄脏的画布，New 取出后必须完全填充背景色
+	dirty := getNRGBA(image.Rect(0, 0, 40, 20))
+	for i := range dirty.Pix {
+		dirty.Pix[i] = uint8(i)
+	}
+	putNRGBA(dirty)
+
+	bg := color.RGBA{R: 10, G: 20, B: 30, A: 255}
+	captcha := New(40, 20, bg)
+	defer captcha.Release()
+	m := captcha.Image().(*image.NRGBA)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if got := m.NRGBAAt(x, y); got != (color.NRGBA(bg)) {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, bg)
+			}
+		}
+	}
+
+	layer := getRGBA(image.Rect(5, 5, 45, 25))
+	layer.Pix[0] = 255
+	putRGBA(layer)
+	if layer = getRGBA(image.Rect(0, 0, 40, 20)); layer.Rect != image.Rect(0, 0, 40, 20) || layer.Pix[0] != 0 {
+		t.Errorf("getRGBA() = %v with first byte %d, want a transparent layer", layer.Rect, layer.Pix[0])
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	captcha := New(60, 30, RandLightColor()).
+		DrawBorder(RandDeepColor()).
+		DrawNoise(NoiseDensityMedium, NewPointNoiseDrawer())
+	defer captcha.Release()
+
+	var want bytes.Buffer
+	if err := png.Encode(&want, captcha.Image()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := captcha.EncodeBytes(ImageFormatPng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第二次编码复用缓冲区，不能影响第一次返回的数据
+	if _, err = captcha.EncodeBytes(ImageFormatJpeg); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Error("EncodeBytes(ImageFormatPng) differs from png.Encode")
+	}
+
+	want.Reset()
+	if err = jpeg.Encode(&want, captcha.Image(), &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = captcha.EncodeBytes(ImageFormatJpeg); err != nil || !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("EncodeBytes(ImageFormatJpeg) differs from jpeg.Encode, error = %v", err)
+	}
+}
+
+// TestPooledPlanesMatchFresh checks that blur and filter passes do not depend on
+// data left in pooled buffers by a previous image.
+func TestPooledPlanesMatchFresh(t *testing.T) {
+	drawers := map[string]func(draw.Image) error{
+		"gaussian": func(m draw.Image) error { return NewGaussianBlur().DrawBlur(m, 5, 1.5) },
+		"box":      func(m draw.Image) error { return NewBoxGaussianBlur(3).DrawBlur(m, 0, 2) },
+		"median":   NewMedianFilter(1).DrawFilter,
+		"motion":   NewMotionBlur(30, 5).DrawFilter,
+		"sharpen":  NewSharpen(1, 1).DrawFilter,
+	}
+	for name, apply := range drawers {
+		t.Run(name, func(t *testing.T) {
+			src := randomNRGBA(80, 40, 1)
+			want := image.NewNRGBA(src.Rect)
+			copy(want.Pix, src.Pix)
+			withFreshPools(func() {
+				if err := apply(want); err != nil {
+					t.Fatal(err)
+				}
+			})
+			// 先处理另一张图片，让池中留下无关的数据
+			if err := apply(randomNRGBA(80, 40, 2)); err != nil {
+				t.Fatal(err)
+			}
+			if err := apply(src); err != nil {
+				t.Fatal(err)
+			}
+			if d, _ := maxPixelDiff(src, want); d != 0 {
+				t.Errorf("pooled result differs by %d", d)
+			}
+		})
+	}
+}
+
+// BenchmarkGenerateCaptchaParallel measures time and allocations per captcha.
+// Compare with an unpooled build using -tags gocaptcha_nopool.
+func BenchmarkGenerateCaptchaParallel(b *testing.B) {
+	for _, difficulty := range []CaptchaDifficulty{CaptchaMedium, CaptchaHard} {
+		b.Run(fmt.Sprintf("difficulty=%d", difficulty), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, _, err := GenerateCaptcha(180, 60, 4, difficulty); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
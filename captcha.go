@@ -2,13 +2,13 @@ package gocaptcha
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/gif"
 	"image/jpeg"
-	"image/png"
 	"io"
 	"math/rand"
 )
@@ -43,11 +43,18 @@ type CaptchaImage struct {
 	height  int
 	Complex int
 	Error   error
+
+	// ctx 取消后，之后的绘制操作不再执行，Error 设为 ctx.Err()
+	ctx context.Context
+
+	caption         *image.NRGBA
+	captionPosition CaptionPosition
 }
 
-// New 新建一个图片对象
+// New 新建一个图片对象.
+// 画布从池中取出，使用完毕后可以调用 Release 归还以减少内存分配.
 func New(width int, height int, bgColor color.RGBA) *CaptchaImage {
-	m := image.NewNRGBA(image.Rect(0, 0, width, height))
+	m := getNRGBA(image.Rect(0, 0, width, height))
 
 	draw.Draw(m, m.Bounds(), &image.Uniform{C: bgColor}, image.Point{}, draw.Src)
 
@@ -58,25 +65,98 @@ func New(width int, height int, bgColor color.RGBA) *CaptchaImage {
 	}
 }
 
-// Encode 编码图片
-func (captcha *CaptchaImage) Encode(w io.Writer, imageFormat ImageFormat) error {
+// NewWithContext 新建一个绑定 ctx 的图片对象，ctx 取消后绘制操作返回 ctx.Err()
+func NewWithContext(ctx context.Context, width int, height int, bgColor color.RGBA) *CaptchaImage {
+	return New(width, height, bgColor).WithContext(ctx)
+}
+
+// WithContext 绑定 ctx，每个绘制步骤开始前以及耗时较长的绘制器内部都会检查 ctx 是否已取消
+func (captcha *CaptchaImage) WithContext(ctx context.Context) *CaptchaImage {
+	captcha.ctx = ctx
+	return captcha
+}
+
+// Context 返回绑定的 ctx，未绑定时返回 context.Background()
+func (captcha *CaptchaImage) Context() context.Context {
+	if captcha.ctx == nil {
+		return context.Background()
+	}
+	return captcha.ctx
+}
+
+// failed 判断之前的步骤是否出错，ctx 已取消时将 Error 设为 ctx.Err()
+func (captcha *CaptchaImage) failed() bool {
+	if captcha.Error == nil && captcha.ctx != nil {
+		captcha.Error = captcha.ctx.Err()
+	}
+	return captcha.Error != nil
+}
 
+// Encode 编码图片，带提示文字条时一并编码
+func (captcha *CaptchaImage) Encode(w io.Writer, imageFormat ImageFormat) error {
+	if err := captcha.Context().Err(); err != nil {
+		return err
+	}
+	if captcha.nrgba == nil {
+		return ErrCaptchaReleased
+	}
+	var m image.Image = captcha.nrgba
+	if captcha.caption != nil {
+		composed := getNRGBA(captcha.captionedBounds())
+		defer putNRGBA(composed)
+		captcha.drawCaptioned(composed)
+		m = composed
+	}
 	if imageFormat == ImageFormatPng {
-		return png.Encode(w, captcha.nrgba)
+		return pngEncoder.Encode(w, m)
 	}
 	if imageFormat == ImageFormatJpeg {
-		return jpeg.Encode(w, captcha.nrgba, &jpeg.Options{Quality: 100})
+		// jpeg 只对 *image.RGBA 直接读取 Pix，其他类型每个像素调用一次 At 并分配内存
+		rgba := getRGBA(m.Bounds())
+		defer putRGBA(rgba)
+		draw.Draw(rgba, rgba.Bounds(), m, m.Bounds().Min, draw.Src)
+		m = rgba
+		bw := getJPEGWriter(w)
+		defer putJPEGWriter(bw)
+		return jpeg.Encode(bw, m, &jpeg.Options{Quality: 100})
 	}
 	if imageFormat == ImageFormatGif {
-		return gif.Encode(w, captcha.nrgba, &gif.Options{NumColors: 256})
+		return gif.Encode(w, m, &gif.Options{NumColors: 256})
 	}
 
 	return errors.New("not supported image format")
 }
 
+// EncodeBytes 将图片编码为字节数组，编码过程使用池中的缓冲区
+func (captcha *CaptchaImage) EncodeBytes(imageFormat ImageFormat) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if bufferPooling {
+		buf = encodeBuffers.Get().(*bytes.Buffer)
+		defer encodeBuffers.Put(buf)
+		buf.Reset()
+	}
+	if err := captcha.Encode(buf, imageFormat); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// Release 将画布归还到池中，之后不能再使用该对象以及 Image 返回的图片.
+// 之后的绘制操作不再执行，Error 设为 ErrCaptchaReleased，重复调用没有影响.
+func (captcha *CaptchaImage) Release() {
+	if captcha.nrgba == nil {
+		return
+	}
+	putNRGBA(captcha.nrgba)
+	captcha.nrgba = nil
+	if captcha.Error == nil {
+		captcha.Error = ErrCaptchaReleased
+	}
+}
+
 // DrawLine 画直线.
 func (captcha *CaptchaImage) DrawLine(drawer LineDrawer, lineColor color.Color) *CaptchaImage {
-	if captcha.Error != nil {
+	if captcha.failed() {
 		return captcha
 	}
 	y := captcha.nrgba.Bounds().Dy()
@@ -88,32 +168,33 @@ func (captcha *CaptchaImage) DrawLine(drawer LineDrawer, lineColor color.Color)
 
 // DrawBorder 画边框.
 func (captcha *CaptchaImage) DrawBorder(borderColor color.RGBA) *CaptchaImage {
-	if captcha.Error != nil {
+	if captcha.failed() {
 		return captcha
 	}
+	setter := newPixelSetter(captcha.nrgba, borderColor)
 	for x := 0; x < captcha.width; x++ {
-		captcha.nrgba.Set(x, 0, borderColor)
-		captcha.nrgba.Set(x, captcha.height-1, borderColor)
+		setter.set(x, 0)
+		setter.set(x, captcha.height-1)
 	}
 	for y := 0; y < captcha.height; y++ {
-		captcha.nrgba.Set(0, y, borderColor)
-		captcha.nrgba.Set(captcha.width-1, y, borderColor)
+		setter.set(0, y)
+		setter.set(captcha.width-1, y)
 	}
 	return captcha
 }
 
 // DrawNoise 画噪点.
 func (captcha *CaptchaImage) DrawNoise(complex NoiseDensity, noiseDrawer NoiseDrawer) *CaptchaImage {
-	if captcha.Error != nil {
+	if captcha.failed() {
 		return captcha
 	}
-	captcha.Error = noiseDrawer.DrawNoise(captcha.nrgba, complex)
+	captcha.Error = drawNoise(captcha.Context(), noiseDrawer, captcha.nrgba, complex)
 	return captcha
 }
 
 // DrawText 写字.
 func (captcha *CaptchaImage) DrawText(textDrawer TextDrawer, text string) *CaptchaImage {
-	if captcha.Error != nil {
+	if captcha.failed() {
 		return captcha
 	}
 	captcha.Error = textDrawer.DrawString(captcha.nrgba, text)
@@ -122,10 +203,21 @@ func (captcha *CaptchaImage) DrawText(textDrawer TextDrawer, text string) *Captc
 
 // DrawBlur 对图片进行模糊处理
 func (captcha *CaptchaImage) DrawBlur(drawer BlurDrawer, kernelSize int, sigma float64) *CaptchaImage {
-	if captcha.Error != nil {
+	if captcha.failed() {
 		return captcha
 	}
-	captcha.Error = drawer.DrawBlur(captcha.nrgba, kernelSize, sigma)
+	captcha.Error = drawBlur(captcha.Context(), drawer, captcha.nrgba, kernelSize, sigma)
+	return captcha
+}
+
+// DrawFilter 依次应用滤镜
+func (captcha *CaptchaImage) DrawFilter(drawers ...FilterDrawer) *CaptchaImage {
+	for _, drawer := range drawers {
+		if captcha.failed() {
+			return captcha
+		}
+		captcha.Error = drawFilter(captcha.Context(), drawer, captcha.nrgba)
+	}
 	return captcha
 }
 
@@ -145,9 +237,34 @@ const (
 
 // GenerateCaptcha 生成验证码图片和对应的文本
 func GenerateCaptcha(width, height int, textLength int, difficulty CaptchaDifficulty) (text string, imgBytes []byte, err error) {
-	// 生成随机文本
-	text = RandText(textLength)
+	return GenerateCaptchaWithGenerator(width, height, textLength, difficulty, DefaultAnswerGenerator)
+}
 
+// GenerateCaptchaContext 与 GenerateCaptcha 相同，ctx 取消或超时后停止绘制并返回 ctx.Err()
+func GenerateCaptchaContext(ctx context.Context, width, height int, textLength int, difficulty CaptchaDifficulty) (text string, imgBytes []byte, err error) {
+	return generateCaptcha(ctx, width, height, textLength, difficulty, DefaultAnswerGenerator)
+}
+
+// GenerateCaptchaWithGenerator 使用指定的答案生成器生成验证码图片和对应的文本
+func GenerateCaptchaWithGenerator(width, height int, textLength int, difficulty CaptchaDifficulty, generator AnswerGenerator) (text string, imgBytes []byte, err error) {
+	return generateCaptcha(context.Background(), width, height, textLength, difficulty, generator)
+}
+
+func generateCaptcha(ctx context.Context, width, height int, textLength int, difficulty CaptchaDifficulty, generator AnswerGenerator) (text string, imgBytes []byte, err error) {
+	// 生成答案文本
+	text, err = generator.Generate(textLength)
+	if err != nil {
+		return "", nil, err
+	}
+	imgBytes, err = renderTextCaptcha(ctx, width, height, text, difficulty, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return text, imgBytes, nil
+}
+
+// renderTextCaptcha 按难度绘制文本验证码并编码为 JPEG，fonts 为空时使用 DefaultFontFamily
+func renderTextCaptcha(ctx context.Context, width, height int, text string, difficulty CaptchaDifficulty, fonts *FontFamily) (imgBytes []byte, err error) {
 	var bgColor color.RGBA
 	var textColor color.RGBA
 	if difficulty == CaptchaVeryEasy {
@@ -200,7 +317,8 @@ func GenerateCaptcha(width, height int, textLength int, difficulty CaptchaDiffic
 	}
 
 	// 创建验证码图片
-	captchaImage := New(width, height, bgColor)
+	captchaImage := NewWithContext(ctx, width, height, bgColor)
+	defer captchaImage.Release()
 
 	// 根据难度选择不同的绘制参数
 	switch difficulty {
@@ -208,14 +326,14 @@ func GenerateCaptcha(width, height int, textLength int, difficulty CaptchaDiffic
 		err = captchaImage.
 			DrawBorder(textColor).
 			// 无扭曲的文字
-			DrawText(NewTwistTextDrawer(DefaultDPI, 0, 0), text).
+			DrawText(NewTwistTextDrawerWithFonts(fonts, DefaultDPI, 0, 0), text).
 			Error
 
 	case CaptchaEasy:
 		err = captchaImage.
 			DrawBorder(RandDeepColor()).
 			// 极轻微的扭曲
-			DrawText(NewTwistTextDrawer(DefaultDPI, DefaultAmplitude/4, DefaultFrequency/4), text).
+			DrawText(NewTwistTextDrawerWithFonts(fonts, DefaultDPI, DefaultAmplitude/4, DefaultFrequency/4), text).
 			// 极少量噪点
 			DrawNoise(NoiseDensityLower/2, NewPointNoiseDrawer()).
 			Error
@@ -226,7 +344,7 @@ func GenerateCaptcha(width, height int, textLength int, difficulty CaptchaDiffic
 			// 只使用较低密度的点状噪点
 			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
 			// 使用更温和的文字扭曲参数
-			DrawText(NewTwistTextDrawer(DefaultDPI, DefaultAmplitude/2, DefaultFrequency/2), text).
+			DrawText(NewTwistTextDrawerWithFonts(fonts, DefaultDPI, DefaultAmplitude/2, DefaultFrequency/2), text).
 			// 只保留一条干扰线
 			DrawLine(NewBeeline(), RandDeepColor()).
 			// 减轻模糊效果
@@ -239,22 +357,16 @@ func GenerateCaptcha(width, height int, textLength int, difficulty CaptchaDiffic
 			DrawNoise(NoiseDensityHigh, NewTextNoiseDrawer(72)).
 			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
 			DrawLine(NewBezier3DLine(), RandDeepColor()).
-			DrawText(NewTwistTextDrawer(DefaultDPI, DefaultAmplitude, DefaultFrequency), text).
+			DrawText(NewTwistTextDrawerWithFonts(fonts, DefaultDPI, DefaultAmplitude, DefaultFrequency), text).
 			DrawLine(NewBeeline(), RandDeepColor()).
 			DrawBlur(NewGaussianBlur(), DefaultBlurKernelSize, DefaultBlurSigma).
 			Error
 	}
 
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
 	// 将图片编码为字节数组
-	buf := new(bytes.Buffer)
-	err = captchaImage.Encode(buf, ImageFormatJpeg)
-	if err != nil {
-		return "", nil, err
-	}
-
-	return text, buf.Bytes(), nil
+	return captchaImage.EncodeBytes(ImageFormatJpeg)
 }
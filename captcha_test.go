@@ -1,8 +1,16 @@
 package gocaptcha
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCaptchaImage_Encode(t *testing.T) {
@@ -41,3 +49,75 @@ func TestGenerateCaptcha(t *testing.T) {
 		t.Fatal("Failed to save captcha image:", err)
 	}
 }
+
+// countdownContext 在 Err 被调用 n 次后变为已取消，用于模拟绘制过程中取消
+type countdownContext struct {
+	context.Context
+	n atomic.Int64
+}
+
+func newCountdownContext(n int64) *countdownContext {
+	ctx := &countdownContext{Context: context.Background()}
+	ctx.n.Store(n)
+	return ctx
+}
+
+func (c *countdownContext) Err() error {
+	if c.n.Add(-1) < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestGenerateCaptchaContext(t *testing.T) {
+	text, imgBytes, err := GenerateCaptchaContext(context.Background(), 180, 60, 4, CaptchaHard)
+	if err != nil || len(text) != 4 || len(imgBytes) == 0 {
+		t.Fatalf("GenerateCaptchaContext() = %q, %d bytes, %v", text, len(imgBytes), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err = GenerateCaptchaContext(ctx, 180, 60, 4, CaptchaHard); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateCaptchaContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, _, err = GenerateCaptchaContext(ctx, 400, 150, 4, CaptchaHard); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GenerateCaptchaContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCaptchaImageContext(t *testing.T) {
+	// 第一次检查在 DrawNoise 开始前，之后文字噪点每画一个字检查一次
+	ctx := newCountdownContext(4)
+	captcha := NewWithContext(ctx, 400, 150, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	lineDrawn := false
+	err := captcha.
+		DrawNoise(NoiseDensityHigh, NewTextNoiseDrawer(DefaultDPI)).
+		DrawLine(lineDrawerFunc(func() { lineDrawn = true }), color.Black).
+		Error
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Error = %v, want %v", err, context.Canceled)
+	}
+	if lineDrawn {
+		t.Error("DrawLine() ran after the context was cancelled")
+	}
+	if err = captcha.Encode(new(bytes.Buffer), ImageFormatPng); !errors.Is(err, context.Canceled) {
+		t.Errorf("Encode() error = %v, want %v", err, context.Canceled)
+	}
+	if captcha.Context() != ctx {
+		t.Error("Context() did not return the bound context")
+	}
+	if New(1, 1, color.RGBA{}).Context() != context.Background() {
+		t.Error("Context() of an unbound image is not context.Background()")
+	}
+}
+
+type lineDrawerFunc func()
+
+func (f lineDrawerFunc) DrawLine(canvas draw.Image, p0 image.Point, p1 image.Point, c color.Color) error {
+	f()
+	return nil
+}
@@ -0,0 +1,255 @@
+package gocaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"unicode"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultCaptionFontSize 默认的提示文字字号
+const DefaultCaptionFontSize = 14.0
+
+// ErrCaptionTooLong 提示文字换行后超过图片高度的一半
+var ErrCaptionTooLong = errors.New("caption does not fit in the image")
+
+// CaptionPosition 提示文字条的位置
+type CaptionPosition int
+
+const (
+	// CaptionTop 提示文字位于验证码上方
+	CaptionTop CaptionPosition = iota
+	// CaptionBottom 提示文字位于验证码下方
+	CaptionBottom
+)
+
+// Caption 绘制在图片中的提示文字，避免机器人直接从 HTML 中读取提示语.
+// 提示文字条占用图片的一部分高度，剩余区域用于绘制验证码.
+type Caption struct {
+	// Text 提示文字，超出宽度时自动换行
+	Text string
+	// Fonts 提示文字使用的字体集，为空时使用 DefaultFontFamily.
+	// 中文提示可使用 CJKFontFamily，其中只保留了验证码和内置中文提示语用到的字形.
+	// 字体集中没有字体包含某个字符时，绘制返回 ErrGlyphMissing
+	Fonts *FontFamily
+	// Size 字号，为 0 时使用 DefaultCaptionFontSize
+	Size float64
+	// Color 文字颜色，为空时使用黑色
+	Color color.Color
+	// Background 文字条背景色，为空时使用图片背景色
+	Background color.Color
+	// Position 文字条位置
+	Position CaptionPosition
+}
+
+// NewCaption 使用 templates 中 lang 对应的模板创建提示文字，找不到时回退到英文.
+// 模板中的格式化动词会被 args 替换，例如 ColorInstructions.
+func NewCaption(templates map[string]string, lang string, args ...interface{}) *Caption {
+	tpl, ok := templates[lang]
+	if !ok {
+		tpl = templates["en"]
+	}
+	if len(args) > 0 {
+		tpl = fmt.Sprintf(tpl, args...)
+	}
+	return &Caption{Text: tpl}
+}
+
+// NewWithCaption 新建一个带提示文字条的图片对象.
+// width 和 height 为整张图片的尺寸，绘制方法只作用于提示文字条以外的验证码区域.
+func NewWithCaption(width int, height int, bgColor color.RGBA, caption *Caption) *CaptchaImage {
+	if caption == nil || caption.Text == "" {
+		return New(width, height, bgColor)
+	}
+	strip, err := caption.render(width, bgColor)
+	if err == nil && strip.Bounds().Dy() > height/2 {
+		err = ErrCaptionTooLong
+	}
+	if err != nil {
+		captcha := New(width, height, bgColor)
+		captcha.Error = err
+		return captcha
+	}
+	captcha := New(width, height-strip.Bounds().Dy(), bgColor)
+	captcha.caption = strip
+	captcha.captionPosition = caption.Position
+	return captcha
+}
+
+// Image 返回包含提示文字条的完整图片，调用 Release 后不能再使用
+func (captcha *CaptchaImage) Image() image.Image {
+	if captcha.caption == nil {
+		return captcha.nrgba
+	}
+	m := image.NewNRGBA(captcha.captionedBounds())
+	captcha.drawCaptioned(m)
+	return m
+}
+
+// captionedBounds 返回包含提示文字条的完整图片的区域
+func (captcha *CaptchaImage) captionedBounds() image.Rectangle {
+	return image.Rect(0, 0, captcha.width, captcha.height+captcha.caption.Bounds().Dy())
+}
+
+// drawCaptioned 将验证码和提示文字条绘制到 m
+func (captcha *CaptchaImage) drawCaptioned(m *image.NRGBA) {
+	stripHeight := captcha.caption.Bounds().Dy()
+	challengeAt, captionAt := image.Pt(0, stripHeight), image.Point{}
+	if captcha.captionPosition == CaptionBottom {
+		challengeAt, captionAt = image.Point{}, image.Pt(0, captcha.height)
+	}
+	draw.Draw(m, captcha.nrgba.Bounds().Add(challengeAt), captcha.nrgba, image.Point{}, draw.Src)
+	draw.Draw(m, captcha.caption.Bounds().Add(captionAt), captcha.caption, image.Point{}, draw.Src)
+}
+
+// render 将提示文字按 width 换行后绘制为文字条
+func (c *Caption) render(width int, bgColor color.Color) (*image.NRGBA, error) {
+	size := c.Size
+	if size <= 0 {
+		size = DefaultCaptionFontSize
+	}
+	fonts := fontFamilyOrDefault(c.Fonts)
+	faces := make(map[*truetype.Font]font.Face)
+	faceFor := func(r rune) (font.Face, error) {
+		f, err := fonts.firstFor(r)
+		if err != nil {
+			return nil, err
+		}
+		face, ok := faces[f]
+		if !ok {
+			face = truetype.NewFace(f, &truetype.Options{Size: size, DPI: DefaultDPI, Hinting: font.HintingFull})
+			faces[f] = face
+		}
+		return face, nil
+	}
+	advance := func(s string) (fixed.Int26_6, error) {
+		var w fixed.Int26_6
+		for _, r := range s {
+			face, err := faceFor(r)
+			if err != nil {
+				return 0, err
+			}
+			a, _ := face.GlyphAdvance(r)
+			w += a
+		}
+		return w, nil
+	}
+
+	padding := int(size/3) + 2
+	lines, err := wrapCaption(c.Text, fixed.I(width-2*padding), advance)
+	if err != nil {
+		return nil, err
+	}
+
+	lineHeight := int(size*1.3 + 0.5)
+	m := image.NewNRGBA(image.Rect(0, 0, width, len(lines)*lineHeight+2*padding))
+	background := c.Background
+	if background == nil {
+		background = bgColor
+	}
+	draw.Draw(m, m.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	textColor := c.Color
+	if textColor == nil {
+		textColor = color.Black
+	}
+	d := &font.Drawer{Dst: m, Src: image.NewUniform(textColor)}
+	for i, line := range lines {
+		w, _ := advance(line)
+		d.Dot = fixed.P(0, padding+i*lineHeight+int(size))
+		d.Dot.X = (fixed.I(width) - w) / 2
+		for _, r := range line {
+			d.Face, _ = faceFor(r)
+			d.DrawString(string(r))
+		}
+	}
+	return m, nil
+}
+
+// wrapCaption 按最大宽度对文字贪心换行.
+// 拉丁文字在空格处换行，中日韩文字可在任意字符之间换行，过长的单词会被截断到下一行.
+func wrapCaption(text string, maxWidth fixed.Int26_6, advance func(string) (fixed.Int26_6, error)) ([]string, error) {
+	var lines []string
+	var line []rune
+	var lineWidth fixed.Int26_6
+	flush := func() {
+		for len(line) > 0 && unicode.IsSpace(line[len(line)-1]) {
+			line = line[:len(line)-1]
+		}
+		lines = append(lines, string(line))
+		line, lineWidth = nil, 0
+	}
+
+	for _, token := range splitCaption(text) {
+		if token == "\n" {
+			flush()
+			continue
+		}
+		if token == " " && len(line) == 0 {
+			continue
+		}
+		w, err := advance(token)
+		if err != nil {
+			return nil, err
+		}
+		if lineWidth+w <= maxWidth {
+			line = append(line, []rune(token)...)
+			lineWidth += w
+			continue
+		}
+		if token == " " {
+			flush()
+			continue
+		}
+		if len(line) > 0 {
+			flush()
+		}
+		// 单词本身超过一行时逐字符换行
+		for _, r := range token {
+			rw, _ := advance(string(r))
+			if lineWidth+rw > maxWidth && len(line) > 0 {
+				flush()
+			}
+			line = append(line, r)
+			lineWidth += rw
+		}
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines, nil
+}
+
+// splitCaption 将文字拆分为可换行的片段：单词、空格、换行符和单个中日韩字符
+func splitCaption(text string) []string {
+	var tokens []string
+	var word []rune
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !isFullWidth(r) {
+			word = append(word, r)
+			continue
+		}
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = nil
+		}
+		switch {
+		case r == '\n':
+			tokens = append(tokens, "\n")
+		case unicode.IsSpace(r):
+			tokens = append(tokens, " ")
+		default:
+			tokens = append(tokens, string(r))
+		}
+	}
+	if len(word) > 0 {
+		tokens = append(tokens, string(word))
+	}
+	return tokens
+}
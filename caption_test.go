@@ -0,0 +1,121 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestWrapCaption(t *testing.T) {
+	// 每个字符宽 10 像素
+	advance := func(s string) (fixed.Int26_6, error) {
+		return fixed.I(10 * len([]rune(s))), nil
+	}
+	tests := []struct {
+		name     string
+		text     string
+		maxWidth int
+		want     []string
+	}{
+		{name: "fits", text: "type red", maxWidth: 100, want: []string{"type red"}},
+		{name: "wrap at space", text: "type only the red", maxWidth: 100, want: []string{"type only", "the red"}},
+		{name: "long word", text: "abcdefghijkl", maxWidth: 50, want: []string{"abcde", "fghij", "kl"}},
+		{name: "cjk", text: "请输入图中所有红色的字符", maxWidth: 50, want: []string{"请输入图中", "所有红色的", "字符"}},
+		{name: "mixed", text: "输入 red 字符", maxWidth: 60, want: []string{"输入 red", "字符"}},
+		{name: "newline", text: "a\nb", maxWidth: 100, want: []string{"a", "b"}},
+		{name: "empty", text: "", maxWidth: 100, want: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wrapCaption(tt.text, fixed.I(tt.maxWidth), advance)
+			if err != nil {
+				t.Fatalf("wrapCaption() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapCaption() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCaption(t *testing.T) {
+	if got := NewCaption(ColorInstructions, "zh", "红色").Text; got != "请输入图中所有红色的字符" {
+		t.Errorf("NewCaption(zh) = %q", got)
+	}
+	if got := NewCaption(ColorInstructions, "xx", "red").Text; got != "Type only the red characters" {
+		t.Errorf("NewCaption(xx) = %q", got)
+	}
+}
+
+func TestNewWithCaption(t *testing.T) {
+	bg := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for _, position := range []CaptionPosition{CaptionTop, CaptionBottom} {
+		captcha := NewWithCaption(200, 100, bg, &Caption{
+			Text:       "Type only the red characters",
+			Size:       12,
+			Background: color.RGBA{A: 255},
+			Color:      color.White,
+			Position:   position,
+		})
+		if captcha.Error != nil {
+			t.Fatalf("NewWithCaption() error = %v", captcha.Error)
+		}
+		if captcha.height >= 100 || captcha.width != 200 {
+			t.Errorf("challenge area = %dx%d, want smaller than 200x100", captcha.width, captcha.height)
+		}
+		m := captcha.Image()
+		if m.Bounds() != image.Rect(0, 0, 200, 100) {
+			t.Fatalf("Image() bounds = %v", m.Bounds())
+		}
+		// 黑色背景的提示文字条应位于指定的一侧
+		captionY, challengeY := 0, 99
+		if position == CaptionBottom {
+			captionY, challengeY = 99, 0
+		}
+		if r, _, _, _ := m.At(0, captionY).RGBA(); r != 0 {
+			t.Errorf("position %d: caption strip not at y=%d", position, captionY)
+		}
+		if r, _, _, _ := m.At(0, challengeY).RGBA(); r != 0xffff {
+			t.Errorf("position %d: challenge area not at y=%d", position, challengeY)
+		}
+	}
+}
+
+func TestNewWithCaptionTooLong(t *testing.T) {
+	captcha := NewWithCaption(60, 40, color.RGBA{A: 255}, &Caption{Text: strings.Repeat("word ", 20)})
+	if !errors.Is(captcha.Error, ErrCaptionTooLong) {
+		t.Errorf("NewWithCaption() error = %v, want %v", captcha.Error, ErrCaptionTooLong)
+	}
+}
+
+func TestGenerateColorCaptchaWithCaption(t *testing.T) {
+	answer, instruction, imgBytes, err := GenerateColorCaptchaWithCaption(240, 100, 6, CaptchaMedium, "en", &Caption{Position: CaptionBottom})
+	if err != nil {
+		t.Fatalf("GenerateColorCaptchaWithCaption() error = %v", err)
+	}
+	if answer == "" || !strings.HasPrefix(instruction, "Type only the ") {
+		t.Errorf("GenerateColorCaptchaWithCaption() = %q, %q", answer, instruction)
+	}
+	m, err := jpeg.Decode(bytes.NewReader(imgBytes))
+	if err != nil {
+		t.Fatalf("jpeg.Decode() error = %v", err)
+	}
+	if m.Bounds() != image.Rect(0, 0, 240, 100) {
+		t.Errorf("image bounds = %v, want 240x100", m.Bounds())
+	}
+}
+
+func TestGenerateColorCaptchaWithCaptionMissingGlyph(t *testing.T) {
+	// 默认字体集只有拉丁字体，中文提示语应报错而不是绘制成方框
+	_, _, _, err := GenerateColorCaptchaWithCaption(240, 100, 6, CaptchaMedium, "zh", &Caption{})
+	if !errors.Is(err, ErrGlyphMissing) {
+		t.Errorf("GenerateColorCaptchaWithCaption() error = %v, want %v", err, ErrGlyphMissing)
+	}
+}
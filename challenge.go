@@ -0,0 +1,381 @@
+package gocaptcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MIMETypeJPEG JPEG 图片
+	MIMETypeJPEG = "image/jpeg"
+
+	// DefaultChallengeWidth 默认的图片宽度
+	DefaultChallengeWidth = 180
+	// DefaultChallengeHeight 默认的图片高度
+	DefaultChallengeHeight = 60
+	// DefaultChallengeLength 默认的答案长度
+	DefaultChallengeLength = 4
+)
+
+// 答案格式，保存在 Payload.Metadata["answer_format"] 中供前端选择输入方式
+const (
+	// AnswerFormatText 输入图片中的文字
+	AnswerFormatText = "text"
+	// AnswerFormatNumber 输入一个整数
+	AnswerFormatNumber = "number"
+	// AnswerFormatTime 输入时间，例如 "3:15"
+	AnswerFormatTime = "time"
+	// AnswerFormatPoint 提交点击位置 "x,y"
+	AnswerFormatPoint = "point"
+	// AnswerFormatNonce 提交工作量证明的 nonce
+	AnswerFormatNonce = "nonce"
+)
+
+var ErrUnknownChallengeType = errors.New("unknown challenge type")
+
+// MediaPart 题目中的一段媒体内容，JSON 中 Data 编码为 base64
+type MediaPart struct {
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// Payload 发送给客户端的公开题目内容
+type Payload struct {
+	Parts    []MediaPart       `json:"parts,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Challenge 统一的验证码题目，适用于图片、点选、工作量证明等各种类型.
+// 校验记录只保存在服务端，不会被序列化.
+type Challenge struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Expires time.Time `json:"expires"`
+	Payload Payload   `json:"payload"`
+
+	record string
+}
+
+// ChallengeOptions 生成题目的参数，未设置的字段使用默认值
+type ChallengeOptions struct {
+	Width      int
+	Height     int
+	Length     int
+	Difficulty CaptchaDifficulty
+	// Locale 提示语的语言，例如 "zh-CN"
+	Locale string
+	// Caption 不为空时将提示语绘制在图片的提示文字条中，不再通过 Metadata 以明文返回.
+	// Text 为空时使用 Locale 对应的提示语，目前用于颜色验证码
+	Caption *Caption
+}
+
+func (o ChallengeOptions) withDefaults() ChallengeOptions {
+	if o.Width <= 0 {
+		o.Width = DefaultChallengeWidth
+	}
+	if o.Height <= 0 {
+		o.Height = DefaultChallengeHeight
+	}
+	if o.Length <= 0 {
+		o.Length = DefaultChallengeLength
+	}
+	return o
+}
+
+// ChallengeGenerator 验证码类型的生成器，每种类型定义自己的答案格式
+type ChallengeGenerator interface {
+	// Generate 生成公开的题目内容和服务端保存的校验记录
+	Generate(opts ChallengeOptions) (payload Payload, record string, err error)
+	// Check 按该类型的答案格式校验答案
+	Check(record string, answer string) bool
+}
+
+// ContextChallengeGenerator 支持取消的生成器，ctx 取消或超时后停止绘制并返回 ctx.Err()
+type ContextChallengeGenerator interface {
+	GenerateContext(ctx context.Context, opts ChallengeOptions) (payload Payload, record string, err error)
+}
+
+// NewChallenge 使用注册表中的类型生成题目，registry 为空时使用 DefaultChallengeRegistry.
+// 返回的题目尚未保存，ID 为空，通常应使用 IssueChallenge 或 Verifier.IssueChallenge.
+func NewChallenge(registry *ChallengeRegistry, typeName string, opts ChallengeOptions) (*Challenge, error) {
+	return NewChallengeContext(context.Background(), registry, typeName, opts)
+}
+
+// NewChallengeContext 与 NewChallenge 相同，生成器实现 ContextChallengeGenerator 时绘制过程中也会检查 ctx
+func NewChallengeContext(ctx context.Context, registry *ChallengeRegistry, typeName string, opts ChallengeOptions) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if registry == nil {
+		registry = DefaultChallengeRegistry
+	}
+	t, ok := registry.Lookup(typeName)
+	if !ok || t.Generator == nil {
+		return nil, ErrUnknownChallengeType
+	}
+	var payload Payload
+	var record string
+	var err error
+	if g, ok := t.Generator.(ContextChallengeGenerator); ok {
+		payload, record, err = g.GenerateContext(ctx, opts.withDefaults())
+	} else {
+		payload, record, err = t.Generator.Generate(opts.withDefaults())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{Type: t.Name, Payload: payload, record: record}, nil
+}
+
+// IssueChallenge 生成题目并保存到 store
+func IssueChallenge(store Store, registry *ChallengeRegistry, typeName string, opts ChallengeOptions) (*Challenge, error) {
+	return (&Verifier{Store: store, Registry: registry}).IssueChallenge("", typeName, opts)
+}
+
+// VerifyChallenge 按题目类型的答案格式校验答案，题目只能校验一次
+func VerifyChallenge(store Store, registry *ChallengeRegistry, id string, answer string) bool {
+	return (&Verifier{Store: store, Registry: registry}).Verify("", id, answer) == nil
+}
+
+// imagePayload 返回只包含一张 JPEG 图片的题目内容
+func imagePayload(imgBytes []byte, answerFormat string) Payload {
+	return Payload{
+		Parts:    []MediaPart{{MIMEType: MIMETypeJPEG, Data: imgBytes}},
+		Metadata: map[string]string{"answer_format": answerFormat},
+	}
+}
+
+// equalFoldAnswer 忽略首尾空白并不区分大小写地比较答案
+func equalFoldAnswer(want string, answer string) bool {
+	return strings.EqualFold(strings.TrimSpace(answer), want)
+}
+
+type textChallenge struct{}
+
+func (c textChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	return c.GenerateContext(context.Background(), opts)
+}
+
+func (textChallenge) GenerateContext(ctx context.Context, opts ChallengeOptions) (Payload, string, error) {
+	text, imgBytes, err := GenerateCaptchaContext(ctx, opts.Width, opts.Height, opts.Length, opts.Difficulty)
+	if err != nil {
+		return Payload{}, "", err
+	}
+	return imagePayload(imgBytes, AnswerFormatText), text, nil
+}
+
+func (textChallenge) Check(record string, answer string) bool {
+	return equalFoldAnswer(record, answer)
+}
+
+type colorChallenge struct{}
+
+func (colorChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	answer, instruction, imgBytes, err := GenerateColorCaptchaWithCaption(opts.Width, opts.Height, opts.Length, opts.Difficulty, localeLanguage(opts.Locale), opts.Caption)
+	if err != nil {
+		return Payload{}, "", err
+	}
+	payload := imagePayload(imgBytes, AnswerFormatText)
+	if opts.Caption == nil {
+		payload.Metadata["instruction"] = instruction
+	}
+	return payload, answer, nil
+}
+
+func (colorChallenge) Check(record string, answer string) bool {
+	return equalFoldAnswer(record, answer)
+}
+
+type clockChallenge struct{}
+
+func (clockChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	t, imgBytes, err := GenerateClockCaptcha(opts.Width, opts.Height, opts.Difficulty)
+	if err != nil {
+		return Payload{}, "", err
+	}
+	return imagePayload(imgBytes, AnswerFormatTime), t.String(), nil
+}
+
+func (clockChallenge) Check(record string, answer string) bool {
+	t, err := ParseClockAnswer(record)
+	return err == nil && t.Match(answer, DefaultClockTolerance)
+}
+
+type diceChallenge struct{}
+
+func (diceChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	answer, imgBytes, err := GenerateDiceCaptcha(opts.Width, opts.Height, opts.Difficulty)
+	if err != nil {
+		return Payload{}, "", err
+	}
+	return imagePayload(imgBytes, AnswerFormatNumber), answer, nil
+}
+
+func (diceChallenge) Check(record string, answer string) bool {
+	return strings.TrimSpace(answer) == record
+}
+
+type oddGlyphChallenge struct{}
+
+func (oddGlyphChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	challenge, imgBytes, err := GenerateOddGlyphCaptcha(opts.Width, opts.Height, opts.Length, opts.Difficulty)
+	if err != nil {
+		return Payload{}, "", err
+	}
+	return imagePayload(imgBytes, AnswerFormatPoint), challenge.record(), nil
+}
+
+// Check 答案为点击位置 "x,y"
+func (oddGlyphChallenge) Check(record string, answer string) bool {
+	xs, ys, ok := strings.Cut(strings.TrimSpace(answer), ",")
+	if !ok {
+		return false
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(xs))
+	if err != nil {
+		return false
+	}
+	y, err := strconv.Atoi(strings.TrimSpace(ys))
+	if err != nil {
+		return false
+	}
+	index, boxes, err := parseGlyphRecord(record)
+	return err == nil && hitTest(boxes, x, y) == index
+}
+
+type cjkChallenge struct{}
+
+func (cjkChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	text, imgBytes, err := GenerateCJKCaptcha(opts.Width, opts.Height, opts.Length, opts.Difficulty)
+	if err != nil {
+		return Payload{}, "", err
+	}
+	return imagePayload(imgBytes, AnswerFormatText), text, nil
+}
+
+// Available 只有加载了汉字字体后才能生成
+func (cjkChallenge) Available() bool {
+	return len(CJKFontFamily.fonts) > 0
+}
+
+// Check 接受汉字或拼音
+func (cjkChallenge) Check(record string, answer string) bool {
+	return MatchCJKAnswer(record, answer, true)
+}
+
+type powChallenge struct{}
+
+// Generate 难度每提高一级增加 2 位，CaptchaMedium 对应 DefaultPoWBits
+func (powChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	c, err := NewPoWChallenge(DefaultPoWBits + 2*(int(opts.Difficulty)-int(CaptchaMedium)))
+	if err != nil {
+		return Payload{}, "", err
+	}
+	return Payload{Metadata: map[string]string{
+		"answer_format": AnswerFormatNonce,
+		"prefix":        c.Prefix,
+		"bits":          strconv.Itoa(c.Bits),
+	}}, c.record(), nil
+}
+
+func (powChallenge) Check(record string, answer string) bool {
+	c, ok := parsePoWRecord(record)
+	return ok && c.Check(strings.TrimSpace(answer))
+}
+
+// ChallengeHandler 统一的验证码 HTTP 处理器.
+//
+//	GET  ...?type=text   返回 JSON 格式的 Challenge，未指定 type 或 Policy 不会选中该类型时由 Policy 选择
+//	POST ...             校验表单中的 id 和 answer，返回 {"success": true|false}
+type ChallengeHandler struct {
+	// Verifier 保存和校验题目，为空时使用 DefaultStore 且每个题目只能校验一次
+	Verifier *Verifier
+	// Policy 选择题目类型并限制请求可以指定的类型，为空时使用 Verifier 注册表上的默认策略
+	Policy *SelectionPolicy
+	// Options 生成题目的参数
+	Options ChallengeOptions
+	// Signals 返回请求的风险分数、设备等信息，为空时只使用 Accept-Language
+	Signals func(r *http.Request) RequestSignals
+	// ClientKey 返回请求的客户端 key，为空时使用 ClientIP
+	ClientKey func(r *http.Request) string
+	// Pool 预生成的题目池，存在与类型同名的预设时从池中取题，忽略 Options 和请求的语言
+	Pool *Pool
+}
+
+// ServeHTTP 实现 http.Handler
+func (h *ChallengeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v := h.Verifier
+	if v == nil {
+		v = &Verifier{}
+	}
+	clientKey := h.ClientKey
+	if clientKey == nil {
+		clientKey = ClientIP
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		signals := RequestSignals{Locale: acceptLanguage(r)}
+		if h.Signals != nil {
+			signals = h.Signals(r)
+		}
+		policy := h.Policy
+		if policy == nil {
+			policy = NewSelectionPolicy(v.registry())
+		}
+		// 客户端指定的类型只有在策略可能选中时才使用，避免高风险的客户端自行选择较容易的类型
+		typeName := r.URL.Query().Get("type")
+		if typeName == "" || !policy.Allows(signals, typeName) {
+			t, err := policy.Select(signals)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			typeName = t.Name
+		}
+		opts := h.Options
+		if opts.Locale == "" {
+			opts.Locale = signals.Locale
+		}
+		var c *Challenge
+		var err error
+		if h.Pool != nil && h.Pool.Has(typeName) {
+			c, err = v.IssuePooled(r.Context(), clientKey(r), h.Pool, typeName)
+		} else {
+			c, err = v.IssueChallengeContext(r.Context(), clientKey(r), typeName, opts)
+		}
+		if errors.Is(err, ErrUnknownChallengeType) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		writeJSON(w, c)
+	case http.MethodPost:
+		err := v.Verify(clientKey(r), r.FormValue("id"), r.FormValue("answer"))
+		switch {
+		case err == nil:
+			writeJSON(w, map[string]bool{"success": true})
+		case errors.Is(err, ErrWrongAnswer), errors.Is(err, ErrCaptchaNotFound), errors.Is(err, ErrTooFast):
+			writeJSON(w, map[string]bool{"success": false})
+		default:
+			WriteError(w, err)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// acceptLanguage 返回 Accept-Language 中的第一个语言
+func acceptLanguage(r *http.Request) string {
+	lang, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
@@ -0,0 +1,257 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// solveChallenge 根据服务端记录构造各类型格式的正确答案
+func solveChallenge(t *testing.T, c *Challenge) string {
+	t.Helper()
+	switch c.Type {
+	case ChallengeOddGlyph:
+		index, boxes, err := parseGlyphRecord(c.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		center := boxes[index].Min.Add(boxes[index].Max).Div(2)
+		return fmt.Sprintf("%d, %d", center.X, center.Y)
+	case ChallengePoW:
+		p, _ := parsePoWRecord(c.record)
+		return p.Solve()
+	}
+	return c.record
+}
+
+func TestIssueVerifyChallenge(t *testing.T) {
+	store := NewMemoryStore(DefaultExpiration)
+	for _, ct := range DefaultChallengeRegistry.Types() {
+		if ct.Name == ChallengeCJK && len(CJKFontFamily.fonts) == 0 {
+			continue
+		}
+		t.Run(ct.Name, func(t *testing.T) {
+			// 工作量证明使用最低难度以加快测试
+			c, err := IssueChallenge(store, nil, ct.Name, ChallengeOptions{Difficulty: CaptchaVeryEasy, Locale: "zh-CN"})
+			if err != nil {
+				t.Fatalf("IssueChallenge() error = %v", err)
+			}
+			if c.ID == "" || c.Type != ct.Name || c.Expires.IsZero() || c.Payload.Metadata["answer_format"] == "" {
+				t.Fatalf("challenge = %+v", c)
+			}
+			if ct.Name != ChallengePoW && (len(c.Payload.Parts) != 1 || c.Payload.Parts[0].MIMEType != MIMETypeJPEG) {
+				t.Errorf("payload parts = %v", c.Payload.Parts)
+			}
+			if !VerifyChallenge(store, nil, c.ID, solveChallenge(t, c)) {
+				t.Error("VerifyChallenge() = false for the right answer")
+			}
+			if VerifyChallenge(store, nil, c.ID, solveChallenge(t, c)) {
+				t.Error("VerifyChallenge() succeeded twice")
+			}
+		})
+	}
+}
+
+func TestChallengeAnswerFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		gen    ChallengeGenerator
+		record string
+		answer string
+		want   bool
+	}{
+		{name: "text case insensitive", gen: textChallenge{}, record: "AbCd", answer: " abcd ", want: true},
+		{name: "clock tolerance", gen: clockChallenge{}, record: "3:15", answer: "15:16", want: true},
+		{name: "clock wrong", gen: clockChallenge{}, record: "3:15", answer: "3:30", want: false},
+		{name: "dice", gen: diceChallenge{}, record: "11", answer: " 11", want: true},
+		{name: "dice wrong", gen: diceChallenge{}, record: "11", answer: "12", want: false},
+		{name: "odd glyph hit", gen: oddGlyphChallenge{}, record: "1|0,0,10,10;10,0,20,10", answer: "15,5", want: true},
+		{name: "odd glyph miss", gen: oddGlyphChallenge{}, record: "1|0,0,10,10;10,0,20,10", answer: "5,5", want: false},
+		{name: "odd glyph malformed", gen: oddGlyphChallenge{}, record: "1|0,0,10,10;10,0,20,10", answer: "15", want: false},
+		{name: "cjk pinyin", gen: cjkChallenge{}, record: "山水", answer: "shan shui", want: true},
+		{name: "pow malformed record", gen: powChallenge{}, record: "garbage", answer: "1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.gen.Check(tt.record, tt.answer); got != tt.want {
+				t.Errorf("Check(%q, %q) = %v, want %v", tt.record, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChallengeJSON(t *testing.T) {
+	c, err := NewChallenge(nil, ChallengeText, ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), c.record) {
+		t.Errorf("serialized challenge leaks the answer: %s", b)
+	}
+	var decoded Challenge
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded.Payload.Parts[0].Data) != string(c.Payload.Parts[0].Data) {
+		t.Error("image data did not round trip through JSON")
+	}
+}
+
+func TestColorChallengeCaption(t *testing.T) {
+	c, err := NewChallenge(nil, ChallengeColor, ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	if c.Payload.Metadata["instruction"] == "" {
+		t.Error("instruction missing from metadata without a caption")
+	}
+	// 提示语绘制在图片中时不再以明文返回
+	c, err = NewChallenge(nil, ChallengeColor, ChallengeOptions{Width: 240, Height: 100, Caption: &Caption{}})
+	if err != nil {
+		t.Fatalf("NewChallenge() with caption error = %v", err)
+	}
+	if instruction, ok := c.Payload.Metadata["instruction"]; ok {
+		t.Errorf("metadata leaks the instruction %q", instruction)
+	}
+}
+
+func TestNewChallengeUnknownType(t *testing.T) {
+	if _, err := NewChallenge(nil, "audio", ChallengeOptions{}); !errors.Is(err, ErrUnknownChallengeType) {
+		t.Errorf("NewChallenge() error = %v, want %v", err, ErrUnknownChallengeType)
+	}
+}
+
+func TestNewChallengeContext(t *testing.T) {
+	// 文本类型在绘制过程中也会检查 ctx
+	_, err := NewChallengeContext(newCountdownContext(2), nil, ChallengeText, ChallengeOptions{Difficulty: CaptchaHard})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("NewChallengeContext() error = %v, want %v", err, context.Canceled)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = NewChallengeContext(ctx, nil, ChallengeDice, ChallengeOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("NewChallengeContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestChallengeHandler(t *testing.T) {
+	v := &Verifier{Store: NewMemoryStore(DefaultExpiration), MaxAttempts: 2}
+	h := &ChallengeHandler{Verifier: v, Options: ChallengeOptions{Difficulty: CaptchaVeryEasy}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/captcha?type=dice", nil))
+	var c Challenge
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatalf("decode challenge: %v (%s)", err, rec.Body)
+	}
+	if c.Type != ChallengeDice {
+		t.Fatalf("challenge type = %q, want %q", c.Type, ChallengeDice)
+	}
+
+	post := func(answer string) string {
+		form := url.Values{"id": {c.ID}, "answer": {answer}}
+		req := httptest.NewRequest(http.MethodPost, "/captcha", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return strings.TrimSpace(rec.Body.String())
+	}
+	if got := post("99"); got != `{"success":false}` {
+		t.Errorf("wrong answer response = %s", got)
+	}
+	if got := post("99"); !strings.Contains(got, ErrTooManyAttempts.Error()) {
+		t.Errorf("second wrong answer response = %s", got)
+	}
+
+	// 未指定类型时由策略选择
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/captcha", nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	h.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil || c.Type == "" {
+		t.Errorf("policy selected challenge = %+v, %v", c, err)
+	}
+
+	// 策略不会选中的类型被忽略
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/captcha?type=audio", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil || c.Type == "" || c.Type == "audio" {
+		t.Errorf("unknown type challenge = %+v, %v", c, err)
+	}
+	h.Signals = func(r *http.Request) RequestSignals { return RequestSignals{Risk: 0.9} }
+	for i := 0; i < 20; i++ {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/captcha?type=pow", nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil || c.Type == ChallengePoW {
+			t.Fatalf("high risk client got challenge %+v, %v", c, err)
+		}
+	}
+}
+
+func TestChallengeHandlerPoW(t *testing.T) {
+	h := &ChallengeHandler{Verifier: &Verifier{Store: NewMemoryStore(DefaultExpiration)}, Options: ChallengeOptions{Difficulty: CaptchaVeryEasy}}
+	issue := func() (Challenge, []byte) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/captcha?type=pow", nil))
+		var c Challenge
+		if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+			t.Fatalf("decode challenge: %v (%s)", err, rec.Body)
+		}
+		if c.Type != ChallengePoW {
+			t.Fatalf("challenge type = %q, want %q", c.Type, ChallengePoW)
+		}
+		return c, rec.Body.Bytes()
+	}
+	post := func(id, nonce string) string {
+		form := url.Values{"id": {id}, "answer": {nonce}}
+		req := httptest.NewRequest(http.MethodPost, "/captcha", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return strings.TrimSpace(rec.Body.String())
+	}
+
+	c, _ := issue()
+	bits, err := strconv.Atoi(c.Payload.Metadata["bits"])
+	if err != nil {
+		t.Fatalf("bits metadata: %v", err)
+	}
+	pow := &PoWChallenge{Prefix: c.Payload.Metadata["prefix"], Bits: bits}
+	if got := post(c.ID, pow.Solve()); got != `{"success":true}` {
+		t.Errorf("verify response = %s", got)
+	}
+
+	// 使用内嵌的求解脚本求解处理器返回的题目
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found, skipping solver.js round trip")
+	}
+	c, body := issue()
+	script := string(PoWSolverJS) + `
+var body = require("fs").readFileSync(0, "utf8");
+globalThis.fetch = function () { return Promise.resolve({ json: function () { return JSON.parse(body); } }); };
+gocaptchaPoW.fetch("/captcha").then(function (r) { process.stdout.write(r.nonce); });
+`
+	cmd := exec.Command(node, "-e", script)
+	cmd.Stdin = bytes.NewReader(body)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("solver.js: %v", err)
+	}
+	if got := post(c.ID, string(out)); got != `{"success":true}` {
+		t.Errorf("solver.js nonce %q: verify response = %s", out, got)
+	}
+}
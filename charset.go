@@ -0,0 +1,257 @@
+package gocaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var ErrCharsetGlyphMissing = errors.New("font family has no glyph for charset character")
+
+// charsetFontFamilies 缓存各字符集推荐字体组成的字体集，避免每次生成都重新子集化
+var charsetFontFamilies sync.Map
+
+// Charset 字符集预设，包含字符、推荐字体和形近字符表
+type Charset struct {
+	// Name 预设名称
+	Name string
+	// Characters 用于生成答案的字符，已去除本文字内部容易混淆的字符
+	Characters []rune
+	// Fonts 推荐使用的字体文件名，内嵌字体使用 "fonts/" 开头的路径
+	Fonts []string
+	// Confusables 形近字符表，校验时会把键替换为值，例如拉丁字母 "P" 替换为西里尔字母 "Р"
+	Confusables map[rune]rune
+}
+
+// embeddedFontNames 内嵌的拉丁字体
+var embeddedFontNames = []string{
+	"fonts/3Dumb.ttf", "fonts/ApothecaryFont.ttf", "fonts/Comismsh.ttf", "fonts/D3Parallelism.ttf",
+	"fonts/DENNEthree-dee.ttf", "fonts/DeborahFancyDress.ttf", "fonts/Esquisito.ttf", "fonts/Flim-Flam.ttf",
+	"fonts/KREMLINGEORGIANI3D.ttf", "fonts/actionj.ttf", "fonts/chromohv.ttf",
+}
+
+// latinDigitConfusables 数字与拉丁字母之间的形近字符
+var latinDigitConfusables = map[rune]rune{
+	'O': '0', 'Q': '0', 'D': '0',
+	'I': '1', 'L': '1', '|': '1',
+	'Z': '2',
+	'S': '5',
+	'B': '8',
+}
+
+var (
+	// CharsetLatinSafe 去除了 I、O、i、l、o 等易混淆字符的拉丁字母和数字，即 TextCharacters
+	CharsetLatinSafe = &Charset{
+		Name:        "latin-safe",
+		Characters:  []rune("ABCDEFGHJKLMNPQRSTUVWXYZabcdefghjkmnpqrstuvwxyz0123456789"),
+		Fonts:       embeddedFontNames,
+		Confusables: map[rune]rune{'O': '0', 'I': '1', '|': '1'},
+	}
+
+	// CharsetDigits 纯数字
+	CharsetDigits = &Charset{
+		Name:        "digits",
+		Characters:  []rune("0123456789"),
+		Fonts:       embeddedFontNames,
+		Confusables: latinDigitConfusables,
+	}
+
+	// CharsetHex 十六进制字符
+	CharsetHex = &Charset{
+		Name:        "hex",
+		Characters:  []rune("0123456789ABCDEF"),
+		Fonts:       embeddedFontNames,
+		Confusables: map[rune]rune{'O': '0', 'Q': '0', 'I': '1', 'L': '1', '|': '1', 'Z': '2', 'S': '5'},
+	}
+
+	// CharsetCyrillic 西里尔大写字母，去除了 Ё、Й、Щ、Ъ、Ь 等与其他字母相近的字符
+	CharsetCyrillic = &Charset{
+		Name:       "cyrillic",
+		Characters: []rune("АБВГДЕЖЗИКЛМНПРСТУФХЦЧШЫЭЮЯ"),
+		Fonts:      []string{"DejaVuSans.ttf", "NotoSans-Regular.ttf", "PTSans-Regular.ttf"},
+		Confusables: map[rune]rune{
+			'A': 'А', 'B': 'В', 'E': 'Е', 'K': 'К', 'M': 'М', 'H': 'Н', 'O': 'О',
+			'P': 'Р', 'C': 'С', 'T': 'Т', 'Y': 'У', 'X': 'Х', 'Ё': 'Е', 'Й': 'И',
+			'3': 'З', '6': 'Б',
+		},
+	}
+
+	// CharsetGreek 希腊大写字母，去除了与数字相近的 Ι、Ο
+	CharsetGreek = &Charset{
+		Name:       "greek",
+		Characters: []rune("ΑΒΓΔΕΖΗΘΚΛΜΝΞΠΡΣΤΥΦΧΨΩ"),
+		Fonts:      []string{"DejaVuSans.ttf", "NotoSans-Regular.ttf", "GFSDidot.ttf"},
+		Confusables: map[rune]rune{
+			'A': 'Α', 'B': 'Β', 'E': 'Ε', 'Z': 'Ζ', 'H': 'Η', 'K': 'Κ', 'M': 'Μ',
+			'N': 'Ν', 'P': 'Ρ', 'T': 'Τ', 'Y': 'Υ', 'X': 'Χ', 'Ϊ': 'Ι', 'Ϋ': 'Υ',
+		},
+	}
+
+	// CharsetArabicIndicDigits 阿拉伯-印度数字，去除了形似句点的 ٠.
+	// 拉丁数字和波斯数字会折叠为对应的阿拉伯-印度数字.
+	CharsetArabicIndicDigits = &Charset{
+		Name:        "arabic-indic-digits",
+		Characters:  []rune("١٢٣٤٥٦٧٨٩"),
+		Fonts:       []string{"NotoSansArabic-Regular.ttf", "DejaVuSans.ttf", "Amiri-Regular.ttf"},
+		Confusables: digitConfusables('٠', '۰'),
+	}
+
+	// CharsetDevanagariDigits 天城文数字，拉丁数字会折叠为对应的天城文数字
+	CharsetDevanagariDigits = &Charset{
+		Name:        "devanagari-digits",
+		Characters:  []rune("०१२३४५६७८९"),
+		Fonts:       []string{"NotoSansDevanagari-Regular.ttf", "Lohit-Devanagari.ttf", "Mangal.ttf"},
+		Confusables: digitConfusables('०'),
+	}
+)
+
+// Charsets 按名称索引的全部字符集预设
+var Charsets = map[string]*Charset{
+	CharsetLatinSafe.Name:         CharsetLatinSafe,
+	CharsetDigits.Name:            CharsetDigits,
+	CharsetHex.Name:               CharsetHex,
+	CharsetCyrillic.Name:          CharsetCyrillic,
+	CharsetGreek.Name:             CharsetGreek,
+	CharsetArabicIndicDigits.Name: CharsetArabicIndicDigits,
+	CharsetDevanagariDigits.Name:  CharsetDevanagariDigits,
+}
+
+// digitConfusables 将拉丁数字以及 others 中各套数字映射到以 zero 开头的数字
+func digitConfusables(zero rune, others ...rune) map[rune]rune {
+	m := make(map[rune]rune)
+	for i := rune(0); i < 10; i++ {
+		m['0'+i] = zero + i
+		for _, other := range others {
+			m[other+i] = zero + i
+		}
+	}
+	return m
+}
+
+// Normalize 将全角字符转为半角，转为大写，并把形近字符替换为规范字符
+func (c *Charset) Normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		// 全角 ASCII 字符
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		r = unicode.ToUpper(r)
+		if v, ok := c.Confusables[r]; ok {
+			return v
+		}
+		return r
+	}, s)
+}
+
+// Match 判断用户输入与答案在规范化后是否一致
+func (c *Charset) Match(want string, answer string) bool {
+	return c.Normalize(want) == c.Normalize(answer)
+}
+
+// Generator 返回从该字符集生成答案并过滤敏感词的生成器
+func (c *Charset) Generator() AnswerGenerator {
+	return NewFilteredGenerator(NewCharsetGenerator(c.Characters), nil)
+}
+
+// FontFamily 在 dirs 中查找推荐字体，并按字符集子集化后组成字体集.
+// 内嵌字体无需指定目录，找不到任何可用字体时返回 ErrNoFontsInFamily.
+func (c *Charset) FontFamily(dirs ...string) (*FontFamily, error) {
+	fonts, _ := NewCustomFontFamily()
+	for _, name := range c.Fonts {
+		candidates := []string{name}
+		for _, dir := range dirs {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+		for _, candidate := range candidates {
+			if _, err := embeddedFonts.Open(filepath.ToSlash(candidate)); err != nil {
+				if _, err = os.Stat(candidate); err != nil {
+					continue
+				}
+			}
+			if err := fonts.AddFontSubset(candidate, c.Characters); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if len(fonts.fonts) == 0 {
+		return nil, ErrNoFontsInFamily
+	}
+	return fonts, nil
+}
+
+// CheckFonts 判断 fonts 中是否有字体包含字符集的每个字符，缺少时返回 ErrCharsetGlyphMissing
+func (c *Charset) CheckFonts(fonts *FontFamily) error {
+	for _, r := range c.Characters {
+		if !fonts.HasGlyph(r) {
+			return fmt.Errorf("%w: %s %q", ErrCharsetGlyphMissing, c.Name, r)
+		}
+	}
+	return nil
+}
+
+// defaultFontFamily 返回并缓存字符集推荐字体组成的字体集
+func (c *Charset) defaultFontFamily() (*FontFamily, error) {
+	if v, ok := charsetFontFamilies.Load(c); ok {
+		return v.(*FontFamily), nil
+	}
+	fonts, err := c.FontFamily()
+	if err != nil {
+		return nil, fmt.Errorf("no recommended font for charset %s: %w", c.Name, err)
+	}
+	v, _ := charsetFontFamilies.LoadOrStore(c, fonts)
+	return v.(*FontFamily), nil
+}
+
+// GenerateCharsetCaptcha 使用字符集预设和字体集生成验证码.
+// fonts 为空时使用字符集推荐的字体，即 charset.FontFamily()，
+// 字体集缺少字符集中的字符时返回 ErrCharsetGlyphMissing，避免生成无法辨认的方框.
+func GenerateCharsetCaptcha(width, height int, textLength int, difficulty CaptchaDifficulty, charset *Charset, fonts *FontFamily) (text string, imgBytes []byte, err error) {
+	if fonts == nil {
+		if fonts, err = charset.defaultFontFamily(); err != nil {
+			return "", nil, err
+		}
+	}
+	if err = charset.CheckFonts(fonts); err != nil {
+		return "", nil, err
+	}
+	text, err = charset.Generator().Generate(textLength)
+	if err != nil {
+		return "", nil, err
+	}
+	imgBytes, err = renderTextCaptcha(context.Background(), width, height, text, difficulty, fonts)
+	if err != nil {
+		return "", nil, err
+	}
+	return text, imgBytes, nil
+}
+
+// IssueCharsetCaptcha 生成字符集验证码并将答案保存到 store，返回验证码 ID 和图片数据，fonts 的用法见 GenerateCharsetCaptcha
+func IssueCharsetCaptcha(store Store, width, height int, textLength int, difficulty CaptchaDifficulty, charset *Charset, fonts *FontFamily) (id string, imgBytes []byte, err error) {
+	text, imgBytes, err := GenerateCharsetCaptcha(width, height, textLength, difficulty, charset, fonts)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err = Issue(store, text)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, imgBytes, nil
+}
+
+// VerifyCharset 按字符集的形近字符表校验答案，验证码只能校验一次
+func VerifyCharset(store Store, id string, answer string, charset *Charset) bool {
+	want, err := getAnswer(store, id)
+	if err != nil {
+		return false
+	}
+	return charset.Match(want, answer)
+}
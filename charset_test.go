@@ -0,0 +1,135 @@
+package gocaptcha
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestCharsetMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset *Charset
+		want    string
+		answer  string
+		match   bool
+	}{
+		{name: "latin case", charset: CharsetLatinSafe, want: "Ab3x", answer: "aB3X", match: true},
+		{name: "latin full width", charset: CharsetLatinSafe, want: "Ab3x", answer: "ＡＢ３Ｘ", match: true},
+		{name: "latin mismatch", charset: CharsetLatinSafe, want: "Ab3x", answer: "Ab3y", match: false},
+		{name: "digits confusable", charset: CharsetDigits, want: "1050", answer: "lO5o", match: true},
+		{name: "hex", charset: CharsetHex, want: "0FA1", answer: "ofai", match: true},
+		{name: "cyrillic latin lookalike", charset: CharsetCyrillic, want: "РАСТ", answer: "PACT", match: true},
+		{name: "cyrillic lower", charset: CharsetCyrillic, want: "ЖЗИ", answer: "жзй", match: true},
+		{name: "greek latin lookalike", charset: CharsetGreek, want: "ΡΗΩ", answer: "phω", match: true},
+		{name: "greek mismatch", charset: CharsetGreek, want: "ΓΔ", answer: "GD", match: false},
+		{name: "arabic from ascii", charset: CharsetArabicIndicDigits, want: "١٢٣", answer: "123", match: true},
+		{name: "arabic from persian", charset: CharsetArabicIndicDigits, want: "٤٥٦", answer: "۴۵۶", match: true},
+		{name: "devanagari from ascii", charset: CharsetDevanagariDigits, want: "९८७", answer: "9 8 7", match: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.charset.Match(tt.want, tt.answer); got != tt.match {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.want, tt.answer, got, tt.match)
+			}
+		})
+	}
+}
+
+func TestCharsetCharactersAreNormalized(t *testing.T) {
+	for name, charset := range Charsets {
+		seen := make(map[string]rune)
+		for _, r := range charset.Characters {
+			n := charset.Normalize(string(r))
+			if prev, ok := seen[n]; ok && charset != CharsetLatinSafe {
+				t.Errorf("%s: %q and %q normalize to the same answer", name, prev, r)
+			}
+			seen[n] = r
+		}
+	}
+}
+
+func TestGenerateCharsetCaptcha(t *testing.T) {
+	fonts, err := CharsetDigits.FontFamily()
+	if err != nil {
+		t.Fatalf("FontFamily() error = %v", err)
+	}
+	store := NewMemoryStore(DefaultExpiration)
+	id, imgBytes, err := IssueCharsetCaptcha(store, 180, 60, 4, CaptchaMedium, CharsetDigits, fonts)
+	if err != nil {
+		t.Fatalf("IssueCharsetCaptcha() error = %v", err)
+	}
+	if len(imgBytes) == 0 {
+		t.Fatal("IssueCharsetCaptcha() returned an empty image")
+	}
+	want, err := store.Get(id, false)
+	if err != nil {
+		t.Fatalf("store.Get() error = %v", err)
+	}
+	for _, r := range want {
+		if r < '0' || r > '9' {
+			t.Fatalf("answer %q contains non-digit %q", want, r)
+		}
+	}
+	if !VerifyCharset(store, id, want, CharsetDigits) {
+		t.Error("VerifyCharset() = false, want true")
+	}
+	if VerifyCharset(store, id, want, CharsetDigits) {
+		t.Error("VerifyCharset() succeeded twice")
+	}
+}
+
+func TestCharsetFontFamilyFromDir(t *testing.T) {
+	dir := "/usr/share/fonts/truetype/dejavu"
+	if _, err := os.Stat(dir); err != nil {
+		t.Skip("dejavu fonts not installed")
+	}
+	fonts, err := CharsetCyrillic.FontFamily(dir)
+	if err != nil {
+		t.Fatalf("FontFamily() error = %v", err)
+	}
+	for _, r := range CharsetCyrillic.Characters {
+		if !fonts.HasGlyph(r) {
+			t.Errorf("font family has no glyph for %q", r)
+		}
+	}
+	text, imgBytes, err := GenerateCharsetCaptcha(180, 60, 4, CaptchaHard, CharsetCyrillic, fonts)
+	if err != nil {
+		t.Fatalf("GenerateCharsetCaptcha() error = %v", err)
+	}
+	if len([]rune(text)) != 4 || len(imgBytes) == 0 {
+		t.Errorf("GenerateCharsetCaptcha() = %q, %d bytes", text, len(imgBytes))
+	}
+}
+
+func TestGenerateCharsetCaptchaDefaultFonts(t *testing.T) {
+	text, imgBytes, err := GenerateCharsetCaptcha(180, 60, 4, CaptchaMedium, CharsetHex, nil)
+	if err != nil {
+		t.Fatalf("GenerateCharsetCaptcha() error = %v", err)
+	}
+	if len(text) != 4 || len(imgBytes) == 0 {
+		t.Errorf("GenerateCharsetCaptcha() = %q, %d bytes", text, len(imgBytes))
+	}
+
+	latin, err := NewCustomFontFamily("fonts/3Dumb.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = GenerateCharsetCaptcha(180, 60, 4, CaptchaMedium, CharsetGreek, latin); !errors.Is(err, ErrCharsetGlyphMissing) {
+		t.Errorf("GenerateCharsetCaptcha() error = %v, want %v", err, ErrCharsetGlyphMissing)
+	}
+
+	// 推荐字体不在当前目录时不能退回到只有拉丁字母的默认字体
+	if _, err = os.Stat(CharsetGreek.Fonts[0]); err == nil {
+		t.Skip("greek font found in the working directory")
+	}
+	if _, _, err = GenerateCharsetCaptcha(180, 60, 4, CaptchaMedium, CharsetGreek, nil); !errors.Is(err, ErrNoFontsInFamily) {
+		t.Errorf("GenerateCharsetCaptcha() error = %v, want %v", err, ErrNoFontsInFamily)
+	}
+}
+
+func TestCharsetFontFamilyMissing(t *testing.T) {
+	if _, err := CharsetDevanagariDigits.FontFamily(t.TempDir()); !errors.Is(err, ErrNoFontsInFamily) {
+		t.Errorf("FontFamily() error = %v, want %v", err, ErrNoFontsInFamily)
+	}
+}
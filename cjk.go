@@ -0,0 +1,225 @@
+package gocaptcha
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CJKFontFamily CJK 验证码使用的字体集.
+// 内嵌字体不包含汉字，使用前需要通过 SetCJKFonts 加载支持汉字的字体.
+var CJKFontFamily, _ = NewCustomFontFamily()
+
+// SetCJKFonts 加载支持汉字的字体文件，如 NotoSansSC-Regular.ttf.
+// 汉字字体通常有数十 MB，加载时只保留 cjkFontCharacters 返回的字符的字形.
+func SetCJKFonts(fonts ...string) error {
+	charset := cjkFontCharacters()
+	for _, font := range fonts {
+		if err := CJKFontFamily.AddFontSubset(font, charset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cjkFontCharacters 返回汉字字体子集需要保留的字符：CJKCharacters、可打印的 ASCII 字符，
+// 以及 ColorInstructions 和 ColorSafePalette 中的中文提示语，使 CJKFontFamily 也能绘制中文提示文字条
+func cjkFontCharacters() []rune {
+	seen := make(map[rune]bool)
+	var charset []rune
+	add := func(r rune) {
+		if !seen[r] && !unicode.IsControl(r) {
+			seen[r] = true
+			charset = append(charset, r)
+		}
+	}
+	for _, r := range CJKCharacters {
+		add(r)
+	}
+	for r := rune(' '); r <= '~'; r++ {
+		add(r)
+	}
+	for _, r := range ColorInstructions["zh"] {
+		add(r)
+	}
+	for _, c := range ColorSafePalette {
+		for _, r := range c.Names["zh"] {
+			add(r)
+		}
+	}
+	return charset
+}
+
+// hanziPinyin 常用汉字及其不带声调的拼音.
+// 已排除多音字和拼音含 ü 的字，避免拼音校验出现歧义.
+var hanziPinyin = map[rune]string{
+	'天': "tian", '人': "ren", '山': "shan", '水': "shui", '火': "huo", '木': "mu", '金': "jin", '土': "tu",
+	'日': "ri", '月': "yue", '星': "xing", '云': "yun", '雨': "yu", '风': "feng", '雪': "xue", '电': "dian",
+	'花': "hua", '草': "cao", '树': "shu", '林': "lin", '森': "sen", '石': "shi", '田': "tian", '米': "mi",
+	'门': "men", '马': "ma", '牛': "niu", '羊': "yang", '鸟': "niao", '鱼': "yu", '虫': "chong", '狗': "gou",
+	'猫': "mao", '手': "shou", '口': "kou", '目': "mu", '耳': "er", '心': "xin", '头': "tou", '身': "shen",
+	'足': "zu", '小': "xiao", '中': "zhong", '上': "shang", '下': "xia", '左': "zuo", '右': "you", '东': "dong",
+	'西': "xi", '南': "nan", '北': "bei", '春': "chun", '夏': "xia", '秋': "qiu", '冬': "dong", '红': "hong",
+	'白': "bai", '黑': "hei", '青': "qing", '黄': "huang", '蓝': "lan", '紫': "zi", '二': "er", '三': "san",
+	'四': "si", '五': "wu", '六': "liu", '七': "qi", '八': "ba", '九': "jiu", '十': "shi", '百': "bai",
+	'千': "qian", '万': "wan", '年': "nian", '书': "shu", '笔': "bi", '船': "chuan", '家': "jia", '国': "guo",
+	'学': "xue", '生': "sheng", '工': "gong", '力': "li", '开': "kai", '关': "guan", '走': "zou", '飞': "fei",
+	'高': "gao", '多': "duo", '少': "shao", '好': "hao", '光': "guang", '明': "ming", '清': "qing", '美': "mei",
+	'新': "xin", '古': "gu", '今': "jin", '来': "lai", '去': "qu", '出': "chu", '入': "ru", '立': "li",
+	'坐': "zuo", '看': "kan", '听': "ting", '笑': "xiao", '哭': "ku", '吃': "chi", '喝': "he", '爱': "ai",
+	'友': "you", '父': "fu", '母': "mu", '兄': "xiong", '弟': "di", '男': "nan", '子': "zi", '王': "wang",
+	'主': "zhu", '公': "gong", '民': "min", '文': "wen", '字': "zi", '语': "yu", '言': "yan", '诗': "shi",
+	'歌': "ge", '画': "hua", '茶': "cha", '饭': "fan", '肉': "rou", '果': "guo", '瓜': "gua", '豆': "dou",
+	'菜': "cai", '酒': "jiu", '糖': "tang", '盐': "yan", '江': "jiang", '河': "he", '湖': "hu", '海': "hai",
+	'岛': "dao", '桥': "qiao", '路': "lu", '城': "cheng", '村': "cun", '镇': "zhen", '街': "jie", '楼': "lou",
+	'窗': "chuang", '灯': "deng", '床': "chuang", '桌': "zhuo", '杯': "bei", '碗': "wan", '刀': "dao", '衣': "yi",
+	'帽': "mao", '鞋': "xie", '伞': "san", '钟': "zhong", '表': "biao", '纸': "zhi", '墨': "mo", '琴': "qin",
+	'棋': "qi", '球': "qiu", '龙': "long", '虎': "hu", '兔': "tu", '猴': "hou", '鸡': "ji", '猪': "zhu",
+	'熊': "xiong", '象': "xiang", '鹿': "lu", '鹤': "he", '松': "song", '竹': "zhu", '梅': "mei", '兰': "lan",
+	'菊': "ju", '桃': "tao", '李': "li", '杏': "xing", '枫': "feng", '早': "zao", '晚': "wan", '午': "wu",
+	'夜': "ye", '晴': "qing", '阳': "yang", '冰': "bing", '霜': "shuang", '雷': "lei", '虹': "hong", '沙': "sha",
+}
+
+// CJKCharacters 用于生成 CJK 验证码的常用汉字
+var CJKCharacters = hanziKeys()
+
+func hanziKeys() []rune {
+	keys := make([]rune, 0, len(hanziPinyin))
+	for r := range hanziPinyin {
+		keys = append(keys, r)
+	}
+	// 固定顺序，保证相同的随机种子生成相同的结果
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Pinyin 返回汉字文本不带声调的拼音，找不到拼音时返回 false
+func Pinyin(text string) (string, bool) {
+	var sb strings.Builder
+	for _, r := range text {
+		py, ok := hanziPinyin[r]
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(py)
+	}
+	return sb.String(), true
+}
+
+// toneReplacer 去掉拼音的声调符号
+var toneReplacer = strings.NewReplacer(
+	"ā", "a", "á", "a", "ǎ", "a", "à", "a",
+	"ē", "e", "é", "e", "ě", "e", "è", "e",
+	"ī", "i", "í", "i", "ǐ", "i", "ì", "i",
+	"ō", "o", "ó", "o", "ǒ", "o", "ò", "o",
+	"ū", "u", "ú", "u", "ǔ", "u", "ù", "u",
+)
+
+// normalizePinyin 转为小写，去掉声调符号、数字声调、空格和隔音符号
+func normalizePinyin(s string) string {
+	s = toneReplacer.Replace(strings.ToLower(s))
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsDigit(r) || r == '\'' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// MatchCJKAnswer 判断用户输入是否与汉字答案一致.
+// 忽略空白（包括全角空格），allowPinyin 为 true 时也接受拼音输入，如 "shan shui"、"shān3shuǐ".
+func MatchCJKAnswer(want string, answer string, allowPinyin bool) bool {
+	input := strings.Join(strings.Fields(answer), "")
+	if input == "" {
+		return false
+	}
+	if input == want {
+		return true
+	}
+	if !allowPinyin {
+		return false
+	}
+	py, ok := Pinyin(want)
+	return ok && normalizePinyin(input) == py
+}
+
+// NewCJKTextDrawer 返回使用 CJKFontFamily 绘制汉字的扭曲文字绘制器
+func NewCJKTextDrawer(dpi float64, amplitude float64, frequency float64) TextDrawer {
+	return NewTwistTextDrawerWithFonts(CJKFontFamily, dpi, amplitude, frequency)
+}
+
+// GenerateCJKCaptcha 生成汉字验证码，返回汉字答案和图片数据.
+// 需要先通过 SetCJKFonts 加载支持汉字的字体.
+func GenerateCJKCaptcha(width, height int, textLength int, difficulty CaptchaDifficulty) (text string, imgBytes []byte, err error) {
+	text, err = NewFilteredGenerator(NewCharsetGenerator(CJKCharacters), nil).Generate(textLength)
+	if err != nil {
+		return "", nil, err
+	}
+
+	bgColor := RandLightColor()
+	bgColor.A = 255
+	captchaImage := New(width, height, bgColor)
+	defer captchaImage.Release()
+
+	// 汉字笔画较多，扭曲和模糊比拉丁字母更温和
+	switch difficulty {
+	case CaptchaVeryEasy:
+		err = captchaImage.
+			DrawBorder(RandDeepColor()).
+			DrawText(NewCJKTextDrawer(DefaultDPI, 0, 0), text).
+			Error
+	case CaptchaEasy:
+		err = captchaImage.
+			DrawBorder(RandDeepColor()).
+			DrawText(NewCJKTextDrawer(DefaultDPI, DefaultAmplitude/8, DefaultFrequency/4), text).
+			DrawNoise(NoiseDensityLower/2, NewPointNoiseDrawer()).
+			Error
+	case CaptchaMedium:
+		err = captchaImage.
+			DrawBorder(RandDeepColor()).
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawText(NewCJKTextDrawer(DefaultDPI, DefaultAmplitude/4, DefaultFrequency/2), text).
+			DrawLine(NewBeeline(), RandDeepColor()).
+			Error
+	default: // CaptchaHard
+		err = captchaImage.
+			DrawBorder(RandDeepColor()).
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawLine(NewBezier3DLine(), RandDeepColor()).
+			DrawText(NewCJKTextDrawer(DefaultDPI, DefaultAmplitude/2, DefaultFrequency/2), text).
+			DrawLine(NewBeeline(), RandDeepColor()).
+			DrawBlur(NewGaussianBlur(), 1, 0.3).
+			Error
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	imgBytes, err = captchaImage.EncodeBytes(ImageFormatJpeg)
+	if err != nil {
+		return "", nil, err
+	}
+	return text, imgBytes, nil
+}
+
+// IssueCJKCaptcha 生成汉字验证码并将答案保存到 store，返回验证码 ID 和图片数据
+func IssueCJKCaptcha(store Store, width, height int, textLength int, difficulty CaptchaDifficulty) (id string, imgBytes []byte, err error) {
+	text, imgBytes, err := GenerateCJKCaptcha(width, height, textLength, difficulty)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err = Issue(store, text)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, imgBytes, nil
+}
+
+// VerifyCJK 校验汉字验证码，allowPinyin 为 true 时也接受拼音输入，验证码只能校验一次
+func VerifyCJK(store Store, id string, answer string, allowPinyin bool) bool {
+	want, err := getAnswer(store, id)
+	if err != nil {
+		return false
+	}
+	return MatchCJKAnswer(want, answer, allowPinyin)
+}
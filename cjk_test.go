@@ -0,0 +1,176 @@
+package gocaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPinyin(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{name: "two characters", text: "山水", want: "shanshui", wantOk: true},
+		{name: "unknown character", text: "山的", wantOk: false},
+		{name: "latin", text: "abc", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Pinyin(tt.text)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("Pinyin() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestMatchCJKAnswer(t *testing.T) {
+	tests := []struct {
+		name        string
+		want        string
+		answer      string
+		allowPinyin bool
+		wantMatch   bool
+	}{
+		{name: "hanzi", want: "山水花", answer: "山水花", wantMatch: true},
+		{name: "hanzi with full width space", want: "山水花", answer: "山　水 花", wantMatch: true},
+		{name: "wrong hanzi", want: "山水花", answer: "山水草", wantMatch: false},
+		{name: "pinyin disabled", want: "山水花", answer: "shanshuihua", wantMatch: false},
+		{name: "pinyin", want: "山水花", answer: "shan shui hua", allowPinyin: true, wantMatch: true},
+		{name: "pinyin with tone marks", want: "山水花", answer: "Shān shuǐ huā", allowPinyin: true, wantMatch: true},
+		{name: "pinyin with tone numbers", want: "山水花", answer: "shan1shui3hua1", allowPinyin: true, wantMatch: true},
+		{name: "wrong pinyin", want: "山水花", answer: "shanshuicao", allowPinyin: true, wantMatch: false},
+		{name: "empty", want: "山水花", answer: " ", allowPinyin: true, wantMatch: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchCJKAnswer(tt.want, tt.answer, tt.allowPinyin); got != tt.wantMatch {
+				t.Errorf("MatchCJKAnswer() = %v, want %v", got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestCJKCharacters(t *testing.T) {
+	if len(CJKCharacters) != len(hanziPinyin) {
+		t.Errorf("len(CJKCharacters) = %d, want %d", len(CJKCharacters), len(hanziPinyin))
+	}
+	for _, r := range CJKCharacters {
+		if !isFullWidth(r) {
+			t.Errorf("%q is not a full width character", r)
+		}
+	}
+}
+
+func TestGenerateCJKCaptcha_NoFont(t *testing.T) {
+	if len(CJKFontFamily.fonts) > 0 {
+		t.Skip("CJK fonts are loaded")
+	}
+	if _, _, err := GenerateCJKCaptcha(180, 60, 4, CaptchaMedium); !errors.Is(err, ErrNoFontsInFamily) {
+		t.Errorf("GenerateCJKCaptcha() error = %v, want %v", err, ErrNoFontsInFamily)
+	}
+}
+
+func TestSetCJKFonts_Subset(t *testing.T) {
+	fontBytes, err := embeddedFonts.ReadFile("fonts/Comismsh.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fontFile := filepath.Join(t.TempDir(), "cjk.ttf")
+	if err = os.WriteFile(fontFile, fontBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := CJKFontFamily
+	CJKFontFamily, _ = NewCustomFontFamily()
+	defer func() { CJKFontFamily = old }()
+
+	if err = SetCJKFonts(fontFile); err != nil {
+		t.Fatalf("SetCJKFonts() error = %v", err)
+	}
+	// 子集保留 ASCII 字符，其他字符不会被加载
+	if len(CJKFontFamily.fonts) != 1 || !CJKFontFamily.HasGlyph('A') || CJKFontFamily.HasGlyph('é') {
+		t.Errorf("SetCJKFonts() loaded %d fonts, HasGlyph('A') = %v, HasGlyph('é') = %v, want a subset font",
+			len(CJKFontFamily.fonts), CJKFontFamily.HasGlyph('A'), CJKFontFamily.HasGlyph('é'))
+	}
+}
+
+func TestSetCJKFonts_Instructions(t *testing.T) {
+	charset := make(map[rune]bool)
+	for _, r := range cjkFontCharacters() {
+		charset[r] = true
+	}
+	var instructions []string
+	for _, c := range ColorSafePalette {
+		instructions = append(instructions, fmt.Sprintf(ColorInstructions["zh"], c.Names["zh"]))
+	}
+	for _, text := range instructions {
+		for _, r := range text {
+			if !charset[r] {
+				t.Errorf("CJK font subset drops %q of instruction %q", r, text)
+			}
+		}
+	}
+
+	// 设置 GOCAPTCHA_CJK_FONT 为汉字字体的路径时，使用真实字体绘制中文提示文字条
+	fontFile := os.Getenv("GOCAPTCHA_CJK_FONT")
+	if fontFile == "" {
+		t.Skip("GOCAPTCHA_CJK_FONT not set")
+	}
+	old := CJKFontFamily
+	CJKFontFamily, _ = NewCustomFontFamily()
+	defer func() { CJKFontFamily = old }()
+	if err := SetCJKFonts(fontFile); err != nil {
+		t.Fatalf("SetCJKFonts() error = %v", err)
+	}
+	f, err := CJKFontFamily.Random()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range instructions {
+		for _, r := range text {
+			if f.Index(r) == 0 {
+				t.Errorf("CJK font has no glyph for %q of instruction %q", r, text)
+			}
+		}
+		captcha := NewWithCaption(180, 90, color.RGBA{R: 255, G: 255, B: 255, A: 255}, &Caption{Text: text, Fonts: CJKFontFamily})
+		if captcha.Error != nil {
+			t.Errorf("NewWithCaption(%q) error = %v", text, captcha.Error)
+		}
+		captcha.Release()
+	}
+}
+
+func TestFontFamily_AddFontFromDisk(t *testing.T) {
+	fontBytes, err := embeddedFonts.ReadFile("fonts/Comismsh.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fontFile := filepath.Join(t.TempDir(), "custom.ttf")
+	if err = os.WriteFile(fontFile, fontBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fonts, err := NewCustomFontFamily(fontFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fonts.HasGlyph('A') || fonts.HasGlyph('山') {
+		t.Errorf("HasGlyph() returned unexpected result")
+	}
+	if f, err := fonts.RandomFor('A'); f == nil || err != nil {
+		t.Errorf("RandomFor() = %v, %v", f, err)
+	}
+
+	drawer := &twistTextDrawer{dpi: DefaultDPI, r: rand.New(rand.NewSource(1)), fonts: fonts}
+	if err = drawer.DrawString(image.NewRGBA(image.Rect(0, 0, 180, 60)), "ABCD"); err != nil {
+		t.Errorf("twistTextDrawer.DrawString() error = %v", err)
+	}
+}
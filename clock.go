@@ -0,0 +1,255 @@
+package gocaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype"
+	"golang.org/x/image/font"
+)
+
+// DefaultClockTolerance 默认允许的分钟误差
+const DefaultClockTolerance = 2
+
+var ErrInvalidClockAnswer = errors.New("invalid clock answer")
+
+// ClockTime 表盘上显示的时间，Hour 取值 1-12，Minute 取值 0-59
+type ClockTime struct {
+	Hour   int
+	Minute int
+}
+
+// RandClockTime 生成随机的表盘时间
+func RandClockTime() ClockTime {
+	return ClockTime{Hour: rand.Intn(12) + 1, Minute: rand.Intn(60)}
+}
+
+// String 返回 "3:05" 格式的时间
+func (t ClockTime) String() string {
+	return fmt.Sprintf("%d:%02d", t.Hour, t.Minute)
+}
+
+// minutes 返回 12 小时制下从 0 点开始的分钟数
+func (t ClockTime) minutes() int {
+	return (t.Hour%12)*60 + t.Minute
+}
+
+// Match 判断用户输入的时间是否与表盘时间一致.
+// 12 小时制与 24 小时制视为相同，tolerance 为允许的分钟误差.
+func (t ClockTime) Match(answer string, tolerance int) bool {
+	got, err := ParseClockAnswer(answer)
+	if err != nil {
+		return false
+	}
+	diff := abs(got.minutes() - t.minutes())
+	if diff > 360 {
+		diff = 720 - diff
+	}
+	return diff <= tolerance
+}
+
+// ParseClockAnswer 解析用户输入的时间.
+// 支持 "3:15"、"15:15"、"03：15"、"0315"、"315" 等格式.
+func ParseClockAnswer(answer string) (ClockTime, error) {
+	answer = strings.TrimSpace(answer)
+	var hourText, minuteText string
+
+	if i := strings.IndexAny(answer, ":：. "); i >= 0 {
+		hourText = answer[:i]
+		minuteText = strings.TrimLeft(answer[i:], ":：. ")
+	} else {
+		if len(answer) < 3 || len(answer) > 4 {
+			return ClockTime{}, ErrInvalidClockAnswer
+		}
+		hourText = answer[:len(answer)-2]
+		minuteText = answer[len(answer)-2:]
+	}
+
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || hour < 0 || hour > 24 {
+		return ClockTime{}, ErrInvalidClockAnswer
+	}
+	minute, err := strconv.Atoi(minuteText)
+	if err != nil || minute < 0 || minute > 59 || len(minuteText) > 2 {
+		return ClockTime{}, ErrInvalidClockAnswer
+	}
+	hour %= 12
+	if hour == 0 {
+		hour = 12
+	}
+	return ClockTime{Hour: hour, Minute: minute}, nil
+}
+
+// ClockDrawer 绘制指针式时钟的接口
+type ClockDrawer interface {
+	DrawClock(canvas draw.Image, t ClockTime) error
+}
+
+type clockDrawer struct {
+	dpi       float64
+	amplitude float64
+	frequency float64
+}
+
+// DrawClock 在画布中央绘制表盘、刻度、数字和时针分针
+func (c *clockDrawer) DrawClock(canvas draw.Image, t ClockTime) error {
+	if canvas == nil {
+		return ErrNilCanvas
+	}
+	bounds := canvas.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	// 先绘制到透明图层上，再整体扭曲到画布
+	layer := image.NewRGBA(bounds)
+
+	cx := width / 2
+	cy := height / 2
+	radius := min(width, height)/2 - 4
+	if radius <= 0 {
+		return nil
+	}
+	faceColor := RandDeepColor()
+	faceColor.A = 255
+
+	drawRing(layer, cx, cy, radius, max(2, radius/30), faceColor)
+
+	// 刻度
+	for i := 0; i < 60; i++ {
+		angle := float64(i) * math.Pi / 30
+		inner := 0.9
+		tickWidth := 1
+		if i%5 == 0 {
+			inner = 0.82
+			tickWidth = 2
+		}
+		drawThickLine(layer, clockPoint(cx, cy, float64(radius)*inner, angle), clockPoint(cx, cy, float64(radius)*0.96, angle), tickWidth, faceColor)
+	}
+
+	// 数字
+	if err := c.drawNumerals(layer, cx, cy, radius, faceColor); err != nil {
+		return err
+	}
+
+	// 时针和分针
+	minuteAngle := float64(t.Minute) * math.Pi / 30
+	hourAngle := (float64(t.Hour%12) + float64(t.Minute)/60) * math.Pi / 6
+	handColor := RandDeepColor()
+	handColor.A = 255
+	drawThickLine(layer, image.Pt(cx, cy), clockPoint(cx, cy, float64(radius)*0.5, hourAngle), max(3, radius/10), handColor)
+	drawThickLine(layer, image.Pt(cx, cy), clockPoint(cx, cy, float64(radius)*0.8, minuteAngle), max(2, radius/20), handColor)
+	drawDisk(layer, cx, cy, max(2, radius/12), handColor)
+
+	return twistEffect(layer, canvas, c.amplitude, c.frequency)
+}
+
+func (c *clockDrawer) drawNumerals(layer draw.Image, cx, cy int, radius int, numeralColor color.Color) error {
+	fc := freetype.NewContext()
+	if c.dpi <= 0 {
+		c.dpi = 72
+	}
+	fontSize := float64(radius) * 0.2
+	fc.SetDPI(c.dpi)
+	fc.SetClip(layer.Bounds())
+	fc.SetDst(layer)
+	fc.SetHinting(font.HintingFull)
+	fc.SetSrc(image.NewUniform(numeralColor))
+	fc.SetFontSize(fontSize)
+
+	f, err := DefaultFontFamily.Random()
+	if err != nil {
+		return err
+	}
+	fc.SetFont(f)
+
+	for i := 1; i <= 12; i++ {
+		text := strconv.Itoa(i)
+		p := clockPoint(cx, cy, float64(radius)*0.68, float64(i)*math.Pi/6)
+		// 以数字中心对齐刻度位置
+		x := p.X - int(fontSize*0.3*float64(len(text)))
+		y := p.Y + int(fontSize*0.35)
+		if _, err = fc.DrawString(text, freetype.Pt(x, y)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clockPoint 返回距圆心 length、从 12 点方向顺时针旋转 angle 弧度的点
+func clockPoint(cx, cy int, length float64, angle float64) image.Point {
+	return image.Point{
+		X: cx + int(math.Round(length*math.Sin(angle))),
+		Y: cy - int(math.Round(length*math.Cos(angle))),
+	}
+}
+
+// NewClockDrawer 返回一个指针式时钟绘制器，amplitude 与 frequency 控制扭曲程度
+func NewClockDrawer(dpi float64, amplitude float64, frequency float64) ClockDrawer {
+	return &clockDrawer{
+		dpi:       dpi,
+		amplitude: amplitude,
+		frequency: frequency,
+	}
+}
+
+// DrawClock 画时钟.
+func (captcha *CaptchaImage) DrawClock(drawer ClockDrawer, t ClockTime) *CaptchaImage {
+	if captcha.failed() {
+		return captcha
+	}
+	captcha.Error = drawer.DrawClock(captcha.nrgba, t)
+	return captcha
+}
+
+// GenerateClockCaptcha 生成读取指针式时钟的验证码，返回表盘时间和图片数据.
+// 可使用 ClockTime.Match 校验用户输入.
+func GenerateClockCaptcha(width, height int, difficulty CaptchaDifficulty) (t ClockTime, imgBytes []byte, err error) {
+	t = RandClockTime()
+	bgColor := RandLightColor()
+	bgColor.A = 255
+	captchaImage := New(width, height, bgColor)
+	defer captchaImage.Release()
+
+	switch difficulty {
+	case CaptchaVeryEasy:
+		err = captchaImage.
+			DrawClock(NewClockDrawer(DefaultDPI, 0, 0), t).
+			Error
+	case CaptchaEasy:
+		err = captchaImage.
+			DrawNoise(NoiseDensityLower/2, NewPointNoiseDrawer()).
+			DrawClock(NewClockDrawer(DefaultDPI, DefaultAmplitude/8, DefaultFrequency/4), t).
+			Error
+	case CaptchaMedium:
+		err = captchaImage.
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawClock(NewClockDrawer(DefaultDPI, DefaultAmplitude/4, DefaultFrequency/2), t).
+			DrawLine(NewBeeline(), RandDeepColor()).
+			DrawBlur(NewGaussianBlur(), 1, 0.3).
+			Error
+	default: // CaptchaHard
+		err = captchaImage.
+			DrawNoise(NoiseDensityMedium, NewPointNoiseDrawer()).
+			DrawLine(NewBezier3DLine(), RandDeepColor()).
+			DrawClock(NewClockDrawer(DefaultDPI, DefaultAmplitude/2, DefaultFrequency), t).
+			DrawLine(NewBeeline(), RandDeepColor()).
+			DrawBlur(NewGaussianBlur(), DefaultBlurKernelSize, DefaultBlurSigma).
+			Error
+	}
+	if err != nil {
+		return ClockTime{}, nil, err
+	}
+
+	imgBytes, err = captchaImage.EncodeBytes(ImageFormatJpeg)
+	if err != nil {
+		return ClockTime{}, nil, err
+	}
+	return t, imgBytes, nil
+}
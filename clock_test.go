@@ -0,0 +1,89 @@
+package gocaptcha
+
+import (
+	"image"
+	"testing"
+)
+
+func TestParseClockAnswer(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		want    ClockTime
+		wantErr bool
+	}{
+		{name: "colon", answer: "3:15", want: ClockTime{Hour: 3, Minute: 15}},
+		{name: "24 hour", answer: "15:15", want: ClockTime{Hour: 3, Minute: 15}},
+		{name: "no separator", answer: "0315", want: ClockTime{Hour: 3, Minute: 15}},
+		{name: "three digits", answer: "315", want: ClockTime{Hour: 3, Minute: 15}},
+		{name: "full width colon", answer: "12：05", want: ClockTime{Hour: 12, Minute: 5}},
+		{name: "midnight", answer: "0:30", want: ClockTime{Hour: 12, Minute: 30}},
+		{name: "spaces", answer: " 9 45 ", want: ClockTime{Hour: 9, Minute: 45}},
+		{name: "invalid minute", answer: "3:75", wantErr: true},
+		{name: "invalid hour", answer: "25:00", wantErr: true},
+		{name: "too short", answer: "15", wantErr: true},
+		{name: "letters", answer: "ab:cd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClockAnswer(tt.answer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseClockAnswer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseClockAnswer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClockTime_Match(t *testing.T) {
+	tests := []struct {
+		name      string
+		t         ClockTime
+		answer    string
+		tolerance int
+		want      bool
+	}{
+		{name: "exact", t: ClockTime{Hour: 3, Minute: 15}, answer: "3:15", tolerance: 0, want: true},
+		{name: "24 hour", t: ClockTime{Hour: 3, Minute: 15}, answer: "15:15", tolerance: 0, want: true},
+		{name: "compact", t: ClockTime{Hour: 3, Minute: 15}, answer: "0315", tolerance: 0, want: true},
+		{name: "within tolerance", t: ClockTime{Hour: 3, Minute: 15}, answer: "3:17", tolerance: 2, want: true},
+		{name: "outside tolerance", t: ClockTime{Hour: 3, Minute: 15}, answer: "3:18", tolerance: 2, want: false},
+		{name: "across twelve", t: ClockTime{Hour: 12, Minute: 1}, answer: "11:59", tolerance: DefaultClockTolerance, want: true},
+		{name: "wrong hour", t: ClockTime{Hour: 3, Minute: 15}, answer: "4:15", tolerance: DefaultClockTolerance, want: false},
+		{name: "garbage", t: ClockTime{Hour: 3, Minute: 15}, answer: "three", tolerance: DefaultClockTolerance, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.t.Match(tt.answer, tt.tolerance); got != tt.want {
+				t.Errorf("ClockTime.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClockDrawer_DrawClock(t *testing.T) {
+	drawer := NewClockDrawer(DefaultDPI, DefaultAmplitude/4, DefaultFrequency)
+	if err := drawer.DrawClock(nil, RandClockTime()); err == nil {
+		t.Errorf("DrawClock() with nil canvas should return error")
+	}
+	if err := drawer.DrawClock(image.NewRGBA(image.Rect(0, 0, 120, 120)), RandClockTime()); err != nil {
+		t.Errorf("DrawClock() error = %v", err)
+	}
+}
+
+func TestGenerateClockCaptcha(t *testing.T) {
+	for _, difficulty := range []CaptchaDifficulty{CaptchaVeryEasy, CaptchaEasy, CaptchaMedium, CaptchaHard} {
+		tm, imgBytes, err := GenerateClockCaptcha(160, 160, difficulty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tm.Match(tm.String(), 0) {
+			t.Errorf("GenerateClockCaptcha() time %v does not match itself", tm)
+		}
+		if len(imgBytes) == 0 {
+			t.Errorf("GenerateClockCaptcha() returned empty image")
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package gocaptcha
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// NamedColor 带名称的颜色，Names 为各语言下的颜色名称
+type NamedColor struct {
+	Color color.RGBA
+	Names map[string]string
+}
+
+// Name 返回指定语言下的颜色名称，找不到时回退到英文
+func (c NamedColor) Name(lang string) string {
+	if name, ok := c.Names[lang]; ok {
+		return name
+	}
+	return c.Names["en"]
+}
+
+// ColorSafePalette 色觉障碍友好的调色板.
+// 颜色取自 Okabe-Ito 配色，红绿色弱、蓝黄色弱的用户也能区分.
+var ColorSafePalette = []NamedColor{
+	{Color: color.RGBA{R: 213, G: 94, B: 0, A: 255}, Names: map[string]string{"en": "red", "zh": "红色"}},
+	{Color: color.RGBA{R: 0, G: 114, B: 178, A: 255}, Names: map[string]string{"en": "blue", "zh": "蓝色"}},
+	{Color: color.RGBA{R: 0, G: 158, B: 115, A: 255}, Names: map[string]string{"en": "green", "zh": "绿色"}},
+	{Color: color.RGBA{R: 0, G: 0, B: 0, A: 255}, Names: map[string]string{"en": "black", "zh": "黑色"}},
+}
+
+// ColorInstructions 各语言的提示语模板，%s 会被替换为目标颜色的名称
+var ColorInstructions = map[string]string{
+	"en": "Type only the %s characters",
+	"zh": "请输入图中所有%s的字符",
+}
+
+// ColorTextChallenge 按颜色挑选字符的验证码题目
+type ColorTextChallenge struct {
+	// Text 图片中展示的全部字符
+	Text string
+	// Colors 每个字符在 Palette 中的颜色下标
+	Colors []int
+	// Target 需要用户输入的颜色在 Palette 中的下标
+	Target int
+	// Palette 使用的调色板
+	Palette []NamedColor
+}
+
+// NewColorTextChallenge 为文本随机分配颜色并选出目标颜色.
+// 目标颜色的字符至少有一个，且不会占满全部字符.
+func NewColorTextChallenge(text string, palette []NamedColor) *ColorTextChallenge {
+	if len(palette) == 0 {
+		palette = ColorSafePalette
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	runes := []rune(text)
+	n := len(runes)
+
+	challenge := &ColorTextChallenge{
+		Text:    text,
+		Colors:  make([]int, n),
+		Target:  r.Intn(len(palette)),
+		Palette: palette,
+	}
+	if n == 0 {
+		return challenge
+	}
+
+	// 目标字符的数量，尽量保证既有目标字符也有干扰字符
+	targetNum := n
+	if n > 1 && len(palette) > 1 {
+		targetNum = 1 + r.Intn(n-1)
+	}
+
+	positions := r.Perm(n)
+	for i, pos := range positions {
+		if i < targetNum {
+			challenge.Colors[pos] = challenge.Target
+			continue
+		}
+		// 从非目标颜色中随机选择
+		c := r.Intn(len(palette) - 1)
+		if c >= challenge.Target {
+			c++
+		}
+		challenge.Colors[pos] = c
+	}
+	return challenge
+}
+
+// Answer 返回目标颜色的字符，按原有顺序排列
+func (c *ColorTextChallenge) Answer() string {
+	var sb strings.Builder
+	for i, s := range []rune(c.Text) {
+		if c.Colors[i] == c.Target {
+			sb.WriteRune(s)
+		}
+	}
+	return sb.String()
+}
+
+// Instruction 返回指定语言的提示语，找不到时回退到英文
+func (c *ColorTextChallenge) Instruction(lang string) string {
+	tpl, ok := ColorInstructions[lang]
+	if !ok {
+		tpl = ColorInstructions["en"]
+	}
+	return fmt.Sprintf(tpl, c.Palette[c.Target].Name(lang))
+}
+
+// colorAt 返回第 i 个字符的颜色
+func (c *ColorTextChallenge) colorAt(i int) color.Color {
+	if i < 0 || i >= len(c.Colors) {
+		return RandDeepColor()
+	}
+	return c.Palette[c.Colors[i]].Color
+}
+
+// NewColorTextDrawer 按题目指定的颜色绘制每个字符，布局与扭曲效果同 NewTwistTextDrawer
+func NewColorTextDrawer(dpi float64, amplitude float64, frequency float64, challenge *ColorTextChallenge) TextDrawer {
+	return &twistTextDrawer{
+		dpi:       dpi,
+		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		amplitude: amplitude,
+		frequency: frequency,
+		colorOf:   challenge.colorAt,
+	}
+}
+
+// GenerateColorCaptcha 生成"只输入某种颜色字符"的验证码.
+// 返回需要用户输入的答案、对应语言的提示语以及图片数据.
+func GenerateColorCaptcha(width, height int, textLength int, difficulty CaptchaDifficulty, lang string) (answer string, instruction string, imgBytes []byte, err error) {
+	return GenerateColorCaptchaWithCaption(width, height, textLength, difficulty, lang, nil)
+}
+
+// GenerateColorCaptchaWithCaption 生成颜色验证码，并将提示语绘制在图片的提示文字条中.
+// caption 的 Text 为空时使用 lang 对应的提示语，caption 为空时不绘制提示文字条.
+func GenerateColorCaptchaWithCaption(width, height int, textLength int, difficulty CaptchaDifficulty, lang string, caption *Caption) (answer string, instruction string, imgBytes []byte, err error) {
+	text, err := DefaultAnswerGenerator.Generate(textLength)
+	if err != nil {
+		return "", "", nil, err
+	}
+	challenge := NewColorTextChallenge(text, ColorSafePalette)
+	// 目标颜色的字符组合在一起也可能构成敏感词
+	for i := 0; i < maxFilterRetries && DefaultProfanityFilter.Contains(challenge.Answer()); i++ {
+		challenge = NewColorTextChallenge(text, ColorSafePalette)
+	}
+
+	// 使用中性的浅色背景和灰色干扰，避免影响颜色的辨认
+	bgColor := color.RGBA{R: 250, G: 250, B: 250, A: 255}
+	grayColor := color.RGBA{R: 160, G: 160, B: 160, A: 255}
+
+	if caption != nil && caption.Text == "" {
+		c := *caption
+		c.Text = challenge.Instruction(lang)
+		caption = &c
+	}
+	captchaImage := NewWithCaption(width, height, bgColor, caption)
+	defer captchaImage.Release()
+
+	switch difficulty {
+	case CaptchaVeryEasy:
+		err = captchaImage.
+			DrawBorder(grayColor).
+			DrawText(NewColorTextDrawer(DefaultDPI, 0, 0, challenge), challenge.Text).
+			Error
+	case CaptchaEasy:
+		err = captchaImage.
+			DrawBorder(grayColor).
+			DrawText(NewColorTextDrawer(DefaultDPI, DefaultAmplitude/4, DefaultFrequency/4, challenge), challenge.Text).
+			DrawNoise(NoiseDensityLower/2, NewPointNoiseDrawer()).
+			Error
+	case CaptchaMedium:
+		err = captchaImage.
+			DrawBorder(grayColor).
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawText(NewColorTextDrawer(DefaultDPI, DefaultAmplitude/2, DefaultFrequency/2, challenge), challenge.Text).
+			DrawLine(NewBeeline(), grayColor).
+			Error
+	default: // CaptchaHard
+		err = captchaImage.
+			DrawBorder(grayColor).
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawLine(NewBezierLine(), grayColor).
+			DrawText(NewColorTextDrawer(DefaultDPI, DefaultAmplitude, DefaultFrequency, challenge), challenge.Text).
+			DrawLine(NewBeeline(), grayColor).
+			DrawBlur(NewGaussianBlur(), 1, 0.3).
+			Error
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	imgBytes, err = captchaImage.EncodeBytes(ImageFormatJpeg)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return challenge.Answer(), challenge.Instruction(lang), imgBytes, nil
+}
@@ -0,0 +1,101 @@
+package gocaptcha
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorTextChallenge_Answer(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		palette []NamedColor
+	}{
+		{
+			name:    "default palette",
+			text:    "AbCdEfGh",
+			palette: ColorSafePalette,
+		},
+		{
+			name:    "nil palette",
+			text:    "xyz123",
+			palette: nil,
+		},
+		{
+			name:    "single character",
+			text:    "Q",
+			palette: ColorSafePalette,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewColorTextChallenge(tt.text, tt.palette)
+			if len(c.Colors) != len([]rune(tt.text)) {
+				t.Fatalf("len(Colors) = %d, want %d", len(c.Colors), len([]rune(tt.text)))
+			}
+			var want strings.Builder
+			for i, s := range []rune(tt.text) {
+				if c.Colors[i] == c.Target {
+					want.WriteRune(s)
+				}
+			}
+			got := c.Answer()
+			if got != want.String() {
+				t.Errorf("Answer() = %v, want %v", got, want.String())
+			}
+			if len(got) == 0 {
+				t.Errorf("Answer() is empty")
+			}
+			if len(tt.text) > 1 && got == tt.text {
+				t.Errorf("Answer() = %v, all characters have the target color", got)
+			}
+		})
+	}
+}
+
+func TestColorTextChallenge_Instruction(t *testing.T) {
+	c := &ColorTextChallenge{Text: "ab", Colors: []int{0, 1}, Target: 0, Palette: ColorSafePalette}
+	tests := []struct {
+		name string
+		lang string
+		want string
+	}{
+		{
+			name: "english",
+			lang: "en",
+			want: "Type only the red characters",
+		},
+		{
+			name: "chinese",
+			lang: "zh",
+			want: "请输入图中所有红色的字符",
+		},
+		{
+			name: "fallback",
+			lang: "xx",
+			want: "Type only the red characters",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Instruction(tt.lang); got != tt.want {
+				t.Errorf("Instruction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateColorCaptcha(t *testing.T) {
+	for _, difficulty := range []CaptchaDifficulty{CaptchaVeryEasy, CaptchaEasy, CaptchaMedium, CaptchaHard} {
+		answer, instruction, imgBytes, err := GenerateColorCaptcha(240, 60, 8, difficulty, "zh")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(answer) == 0 || len(answer) >= 8 {
+			t.Errorf("GenerateColorCaptcha() answer = %v", answer)
+		}
+		if len(instruction) == 0 || len(imgBytes) == 0 {
+			t.Errorf("GenerateColorCaptcha() instruction = %v, len(imgBytes) = %d", instruction, len(imgBytes))
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package gocaptcha
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// dicePips 每个点数对应的点在骰面上的位置（单位正方形坐标）
+var dicePips = [7][][2]float64{
+	1: {{0.5, 0.5}},
+	2: {{0.25, 0.25}, {0.75, 0.75}},
+	3: {{0.25, 0.25}, {0.5, 0.5}, {0.75, 0.75}},
+	4: {{0.25, 0.25}, {0.75, 0.25}, {0.25, 0.75}, {0.75, 0.75}},
+	5: {{0.25, 0.25}, {0.75, 0.25}, {0.5, 0.5}, {0.25, 0.75}, {0.75, 0.75}},
+	6: {{0.25, 0.25}, {0.75, 0.25}, {0.25, 0.5}, {0.75, 0.5}, {0.25, 0.75}, {0.75, 0.75}},
+}
+
+// RandDiceFaces 随机生成 num 个骰子的点数
+func RandDiceFaces(num int) []int {
+	faces := make([]int, num)
+	for i := range faces {
+		faces[i] = rand.Intn(6) + 1
+	}
+	return faces
+}
+
+// DiceSum 返回骰子点数之和
+func DiceSum(faces []int) int {
+	sum := 0
+	for _, face := range faces {
+		sum += face
+	}
+	return sum
+}
+
+// DiceDrawer 绘制骰子的接口
+type DiceDrawer interface {
+	DrawDice(canvas draw.Image, faces []int) error
+}
+
+type diceDrawer struct {
+	r *rand.Rand
+}
+
+// DrawDice 将骰子从左到右排列绘制，每个骰子随机旋转并带有透视变形
+func (d *diceDrawer) DrawDice(canvas draw.Image, faces []int) error {
+	if canvas == nil {
+		return ErrNilCanvas
+	}
+	if len(faces) == 0 {
+		return nil
+	}
+	bounds := canvas.Bounds()
+	cellWidth := float64(bounds.Dx()) / float64(len(faces))
+	height := float64(bounds.Dy())
+
+	for i, face := range faces {
+		// 旋转后骰子的对角线不能超出单元格
+		side := math.Min(cellWidth, height) * (0.5 + d.r.Float64()*0.1)
+		half := side / 2
+		cx := float64(bounds.Min.X) + cellWidth*(float64(i)+0.5) + (d.r.Float64()-0.5)*(cellWidth-side*1.4)*0.5
+		cy := float64(bounds.Min.Y) + height/2 + (d.r.Float64()-0.5)*(height-side*1.4)*0.5
+		angle := d.r.Float64() * math.Pi / 2
+
+		// 四个角按顺时针排列，随机偏移模拟透视
+		var quad [4][2]float64
+		for j, corner := range [4][2]float64{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+			x := corner[0]*half + (d.r.Float64()-0.5)*side*0.2
+			y := corner[1]*half + (d.r.Float64()-0.5)*side*0.2
+			quad[j] = [2]float64{
+				cx + x*math.Cos(angle) - y*math.Sin(angle),
+				cy + x*math.Sin(angle) + y*math.Cos(angle),
+			}
+		}
+		d.drawDie(canvas, quad, face)
+	}
+	return nil
+}
+
+// drawDie 把单位正方形上的骰面通过透视变换映射到四边形 quad 上
+func (d *diceDrawer) drawDie(canvas draw.Image, quad [4][2]float64, face int) {
+	inverse, ok := invertMatrix3(squareToQuad(quad))
+	if !ok {
+		return
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range quad {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+
+	// 骰面底色和点的颜色
+	base := 225 + d.r.Intn(31)
+	faceColor := [3]float64{float64(base), float64(base - d.r.Intn(20)), float64(base - d.r.Intn(40))}
+	pipColor := color.RGBA{R: uint8(d.r.Intn(40)), G: uint8(d.r.Intn(40)), B: uint8(d.r.Intn(40)), A: 255}
+	if face == 1 {
+		pipColor = color.RGBA{R: 180 + uint8(d.r.Intn(60)), G: uint8(d.r.Intn(40)), B: uint8(d.r.Intn(40)), A: 255}
+	}
+	// 光源方向，决定渐变阴影的方向
+	light := d.r.Float64() * 2 * math.Pi
+
+	const corner = 0.15
+	const pipRadius = 0.09
+	bounds := canvas.Bounds()
+	for y := int(minY); y <= int(maxY)+1; y++ {
+		for x := int(minX); x <= int(maxX)+1; x++ {
+			if !image.Pt(x, y).In(bounds) {
+				continue
+			}
+			u, v, ok := applyMatrix3(inverse, float64(x)+0.5, float64(y)+0.5)
+			if !ok || u < 0 || u > 1 || v < 0 || v > 1 {
+				continue
+			}
+			// 圆角
+			cu := math.Min(math.Max(u, corner), 1-corner)
+			cv := math.Min(math.Max(v, corner), 1-corner)
+			if math.Hypot(u-cu, v-cv) > corner {
+				continue
+			}
+
+			inPip := false
+			for _, pip := range dicePips[face] {
+				if math.Hypot(u-pip[0], v-pip[1]) <= pipRadius {
+					inPip = true
+					break
+				}
+			}
+			if inPip {
+				canvas.Set(x, y, pipColor)
+				continue
+			}
+
+			// 沿光源方向的线性渐变，边缘处额外压暗模拟倒角
+			shade := 0.85 + 0.15*((u-0.5)*math.Cos(light)+(v-0.5)*math.Sin(light))
+			edge := math.Min(math.Min(u, 1-u), math.Min(v, 1-v))
+			if edge < 0.06 {
+				shade *= 0.75 + edge/0.06*0.25
+			}
+			canvas.Set(x, y, color.RGBA{
+				R: uint8(math.Min(255, faceColor[0]*shade)),
+				G: uint8(math.Min(255, faceColor[1]*shade)),
+				B: uint8(math.Min(255, faceColor[2]*shade)),
+				A: 255,
+			})
+		}
+	}
+}
+
+// squareToQuad 计算把单位正方形映射到四边形 q 的透视变换矩阵
+func squareToQuad(q [4][2]float64) [9]float64 {
+	sx := q[0][0] - q[1][0] + q[2][0] - q[3][0]
+	sy := q[0][1] - q[1][1] + q[2][1] - q[3][1]
+	if sx == 0 && sy == 0 {
+		return [9]float64{
+			q[1][0] - q[0][0], q[3][0] - q[0][0], q[0][0],
+			q[1][1] - q[0][1], q[3][1] - q[0][1], q[0][1],
+			0, 0, 1,
+		}
+	}
+	dx1, dx2 := q[1][0]-q[2][0], q[3][0]-q[2][0]
+	dy1, dy2 := q[1][1]-q[2][1], q[3][1]-q[2][1]
+	den := dx1*dy2 - dx2*dy1
+	g := (sx*dy2 - dx2*sy) / den
+	h := (dx1*sy - sx*dy1) / den
+	return [9]float64{
+		q[1][0] - q[0][0] + g*q[1][0], q[3][0] - q[0][0] + h*q[3][0], q[0][0],
+		q[1][1] - q[0][1] + g*q[1][1], q[3][1] - q[0][1] + h*q[3][1], q[0][1],
+		g, h, 1,
+	}
+}
+
+// invertMatrix3 求 3x3 矩阵的逆矩阵
+func invertMatrix3(m [9]float64) ([9]float64, bool) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+	if det == 0 {
+		return [9]float64{}, false
+	}
+	return [9]float64{
+		(m[4]*m[8] - m[5]*m[7]) / det, (m[2]*m[7] - m[1]*m[8]) / det, (m[1]*m[5] - m[2]*m[4]) / det,
+		(m[5]*m[6] - m[3]*m[8]) / det, (m[0]*m[8] - m[2]*m[6]) / det, (m[2]*m[3] - m[0]*m[5]) / det,
+		(m[3]*m[7] - m[4]*m[6]) / det, (m[1]*m[6] - m[0]*m[7]) / det, (m[0]*m[4] - m[1]*m[3]) / det,
+	}, true
+}
+
+// applyMatrix3 对点 (x, y) 应用透视变换
+func applyMatrix3(m [9]float64, x, y float64) (float64, float64, bool) {
+	w := m[6]*x + m[7]*y + m[8]
+	if w == 0 {
+		return 0, 0, false
+	}
+	return (m[0]*x + m[1]*y + m[2]) / w, (m[3]*x + m[4]*y + m[5]) / w, true
+}
+
+// NewDiceDrawer 返回一个骰子绘制器
+func NewDiceDrawer() DiceDrawer {
+	return &diceDrawer{
+		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// DrawDice 画骰子.
+func (captcha *CaptchaImage) DrawDice(drawer DiceDrawer, faces []int) *CaptchaImage {
+	if captcha.failed() {
+		return captcha
+	}
+	captcha.Error = drawer.DrawDice(captcha.nrgba, faces)
+	return captcha
+}
+
+// GenerateDiceCaptcha 生成 2 到 4 个骰子的图片，答案为所有骰子的点数之和.
+// 该验证码不依赖任何语言，适合国际化场景.
+func GenerateDiceCaptcha(width, height int, difficulty CaptchaDifficulty) (answer string, imgBytes []byte, err error) {
+	faces := RandDiceFaces(2 + rand.Intn(3))
+
+	bgColor := RandLightColor()
+	bgColor.A = 255
+	captchaImage := New(width, height, bgColor)
+	defer captchaImage.Release()
+
+	switch difficulty {
+	case CaptchaVeryEasy:
+		err = captchaImage.
+			DrawDice(NewDiceDrawer(), faces).
+			Error
+	case CaptchaEasy:
+		err = captchaImage.
+			DrawDice(NewDiceDrawer(), faces).
+			DrawNoise(NoiseDensityLower/2, NewPointNoiseDrawer()).
+			Error
+	case CaptchaMedium:
+		err = captchaImage.
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawDice(NewDiceDrawer(), faces).
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawLine(NewBezier3DLine(), RandDeepColor()).
+			Error
+	default: // CaptchaHard
+		err = captchaImage.
+			DrawNoise(NoiseDensityMedium, NewPointNoiseDrawer()).
+			DrawDice(NewDiceDrawer(), faces).
+			DrawNoise(NoiseDensityMedium, NewPointNoiseDrawer()).
+			DrawLine(NewBezier3DLine(), RandDeepColor()).
+			DrawLine(NewBezierLine(), RandDeepColor()).
+			DrawBlur(NewGaussianBlur(), DefaultBlurKernelSize, DefaultBlurSigma).
+			Error
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	imgBytes, err = captchaImage.EncodeBytes(ImageFormatJpeg)
+	if err != nil {
+		return "", nil, err
+	}
+	return strconv.Itoa(DiceSum(faces)), imgBytes, nil
+}
+
+// IssueDiceCaptcha 生成骰子验证码并将答案保存到 store，返回验证码 ID 和图片数据
+func IssueDiceCaptcha(store Store, width, height int, difficulty CaptchaDifficulty) (id string, imgBytes []byte, err error) {
+	answer, imgBytes, err := GenerateDiceCaptcha(width, height, difficulty)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err = Issue(store, answer)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, imgBytes, nil
+}
@@ -0,0 +1,91 @@
+package gocaptcha
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestDiceSum(t *testing.T) {
+	tests := []struct {
+		name  string
+		faces []int
+		want  int
+	}{
+		{name: "empty", faces: nil, want: 0},
+		{name: "two dice", faces: []int{1, 6}, want: 7},
+		{name: "four dice", faces: []int{6, 6, 6, 6}, want: 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiceSum(tt.faces); got != tt.want {
+				t.Errorf("DiceSum() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSquareToQuad(t *testing.T) {
+	quad := [4][2]float64{{10, 12}, {52, 8}, {60, 49}, {6, 55}}
+	m := squareToQuad(quad)
+	inverse, ok := invertMatrix3(m)
+	if !ok {
+		t.Fatal("invertMatrix3() failed")
+	}
+	for i, uv := range [4][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		x, y, _ := applyMatrix3(m, uv[0], uv[1])
+		if math.Abs(x-quad[i][0]) > 1e-9 || math.Abs(y-quad[i][1]) > 1e-9 {
+			t.Errorf("corner %d = (%v, %v), want %v", i, x, y, quad[i])
+		}
+		u, v, _ := applyMatrix3(inverse, quad[i][0], quad[i][1])
+		if math.Abs(u-uv[0]) > 1e-9 || math.Abs(v-uv[1]) > 1e-9 {
+			t.Errorf("inverse corner %d = (%v, %v), want %v", i, u, v, uv)
+		}
+	}
+}
+
+func TestDiceDrawer_DrawDice(t *testing.T) {
+	tests := []struct {
+		name    string
+		canvas  draw.Image
+		faces   []int
+		wantErr bool
+	}{
+		{name: "nil canvas", canvas: nil, faces: []int{1, 2}, wantErr: true},
+		{name: "no dice", canvas: image.NewRGBA(image.Rect(0, 0, 100, 50)), faces: nil},
+		{name: "all faces", canvas: image.NewRGBA(image.Rect(0, 0, 300, 60)), faces: []int{1, 2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewDiceDrawer().DrawDice(tt.canvas, tt.faces); (err != nil) != tt.wantErr {
+				t.Errorf("diceDrawer.DrawDice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIssueDiceCaptcha(t *testing.T) {
+	store := NewMemoryStore(DefaultExpiration)
+	for _, difficulty := range []CaptchaDifficulty{CaptchaVeryEasy, CaptchaEasy, CaptchaMedium, CaptchaHard} {
+		id, imgBytes, err := IssueDiceCaptcha(store, 240, 90, difficulty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(imgBytes) == 0 {
+			t.Errorf("IssueDiceCaptcha() returned empty image")
+		}
+		answer, err := store.Get(id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := strconv.Atoi(answer)
+		if err != nil || sum < 2 || sum > 24 {
+			t.Errorf("IssueDiceCaptcha() answer = %v", answer)
+		}
+		if !Verify(store, id, answer) {
+			t.Errorf("Verify() = false, want true")
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package gocaptcha
+
+import (
+	"context"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// FilterDrawer is an image filter whose parameters are fixed when it is created.
+type FilterDrawer interface {
+	DrawFilter(canvas draw.Image) error
+}
+
+// ContextFilterDrawer is implemented by filters that can stop early when ctx is done.
+// A cancelled filter leaves the canvas unchanged.
+type ContextFilterDrawer interface {
+	DrawFilterContext(ctx context.Context, canvas draw.Image) error
+}
+
+// drawFilter calls DrawFilterContext when drawer supports it, otherwise DrawFilter.
+func drawFilter(ctx context.Context, drawer FilterDrawer, canvas draw.Image) error {
+	if d, ok := drawer.(ContextFilterDrawer); ok {
+		return d.DrawFilterContext(ctx, canvas)
+	}
+	return drawer.DrawFilter(canvas)
+}
+
+type blurFilter struct {
+	drawer     BlurDrawer
+	kernelSize int
+	sigma      float64
+}
+
+// NewBlurFilter adapts a BlurDrawer to the FilterDrawer interface.
+func NewBlurFilter(drawer BlurDrawer, kernelSize int, sigma float64) FilterDrawer {
+	return &blurFilter{drawer: drawer, kernelSize: kernelSize, sigma: sigma}
+}
+
+func (f *blurFilter) DrawFilter(canvas draw.Image) error {
+	return f.drawer.DrawBlur(canvas, f.kernelSize, f.sigma)
+}
+
+func (f *blurFilter) DrawFilterContext(ctx context.Context, canvas draw.Image) error {
+	return drawBlur(ctx, f.drawer, canvas, f.kernelSize, f.sigma)
+}
+
+type motionBlur struct {
+	offsets [][2]int
+}
+
+// NewMotionBlur returns a filter that smears the image along a line of the given
+// length in pixels. angle is in degrees, 0 is horizontal and 90 is vertical.
+func NewMotionBlur(angle float64, length int) FilterDrawer {
+	if length < 1 {
+		length = 1
+	}
+	rad := angle * math.Pi / 180
+	dx, dy := math.Cos(rad), math.Sin(rad)
+	offsets := make([][2]int, 0, length)
+	for i := 0; i < length; i++ {
+		t := float64(i) - float64(length-1)/2
+		offsets = append(offsets, [2]int{int(math.Round(t * dx)), int(math.Round(t * dy))})
+	}
+	return &motionBlur{offsets: offsets}
+}
+
+func (f *motionBlur) DrawFilter(canvas draw.Image) error {
+	return f.DrawFilterContext(context.Background(), canvas)
+}
+
+// DrawFilterContext checks ctx once per row.
+func (f *motionBlur) DrawFilterContext(ctx context.Context, canvas draw.Image) error {
+	p := loadPlanes(canvas)
+	defer p.release()
+	srcPlanes := p.scratch()
+	defer srcPlanes.release()
+	src := srcPlanes.pix
+	copy(src, p.pix)
+	scale := 1 / float64(len(f.offsets))
+	err := parallelBands(p.h, minParallelRows, func(y0, y1 int) error {
+		for y := y0; y < y1; y++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for x := 0; x < p.w; x++ {
+				var r, g, b float64
+				for _, o := range f.offsets {
+					i := 3 * (clampInt(y+o[1], 0, p.h-1)*p.w + clampInt(x+o[0], 0, p.w-1))
+					r, g, b = r+src[i], g+src[i+1], b+src[i+2]
+				}
+				i := 3 * (y*p.w + x)
+				p.pix[i], p.pix[i+1], p.pix[i+2] = r*scale, g*scale, b*scale
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.store(canvas)
+	return nil
+}
+
+type medianFilter struct {
+	radius int
+}
+
+// NewMedianFilter returns a filter that replaces each channel with the median of the
+// (2*radius+1)² neighbourhood. It removes isolated salt-and-pepper noise while
+// keeping edges sharp.
+func NewMedianFilter(radius int) FilterDrawer {
+	if radius < 1 {
+		radius = 1
+	}
+	return &medianFilter{radius: radius}
+}
+
+func (f *medianFilter) DrawFilter(canvas draw.Image) error {
+	return f.DrawFilterContext(context.Background(), canvas)
+}
+
+// DrawFilterContext checks ctx once per row.
+func (f *medianFilter) DrawFilterContext(ctx context.Context, canvas draw.Image) error {
+	p := loadPlanes(canvas)
+	defer p.release()
+	srcPlanes := p.scratch()
+	defer srcPlanes.release()
+	src := srcPlanes.pix
+	copy(src, p.pix)
+	size := 2*f.radius + 1
+	err := parallelBands(p.h, minParallelRows, func(y0, y1 int) error {
+		window := make([]float64, size*size)
+		for y := y0; y < y1; y++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for x := 0; x < p.w; x++ {
+				for c := 0; c < 3; c++ {
+					n := 0
+					for ky := -f.radius; ky <= f.radius; ky++ {
+						row := clampInt(y+ky, 0, p.h-1) * p.w
+						for kx := -f.radius; kx <= f.radius; kx++ {
+							window[n] = src[3*(row+clampInt(x+kx, 0, p.w-1))+c]
+							n++
+						}
+					}
+					sort.Float64s(window)
+					p.pix[3*(y*p.w+x)+c] = window[len(window)/2]
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.store(canvas)
+	return nil
+}
+
+type boxFilter struct {
+	radius int
+}
+
+// NewBoxBlur returns a filter that averages each pixel with its (2*radius+1)² neighbourhood.
+func NewBoxBlur(radius int) FilterDrawer {
+	if radius < 1 {
+		radius = 1
+	}
+	return &boxFilter{radius: radius}
+}
+
+func (f *boxFilter) DrawFilter(canvas draw.Image) error {
+	p := loadPlanes(canvas)
+	defer p.release()
+	tmp := p.scratch()
+	defer tmp.release()
+	boxRows(tmp.pix, p.pix, p.w, p.h, f.radius)
+	boxColumns(p.pix, tmp.pix, p.w, p.h, f.radius)
+	p.store(canvas)
+	return nil
+}
+
+type sharpenFilter struct {
+	amount float64
+	sigma  float64
+}
+
+// NewSharpen returns an unsharp mask filter: the difference between the image and a
+// Gaussian blur of radius sigma is scaled by amount and added back.
+func NewSharpen(amount float64, sigma float64) FilterDrawer {
+	if sigma <= 0 {
+		sigma = 1
+	}
+	return &sharpenFilter{amount: amount, sigma: sigma}
+}
+
+func (f *sharpenFilter) DrawFilter(canvas draw.Image) error {
+	p := loadPlanes(canvas)
+	defer p.release()
+	blurredPlanes := p.scratch()
+	defer blurredPlanes.release()
+	blurred := blurredPlanes.pix
+	copy(blurred, p.pix)
+	tmp := p.scratch()
+	defer tmp.release()
+	// the box approximation extends the edges, so borders are not darkened and then over-sharpened
+	for _, size := range boxSizesForGauss(f.sigma, 3) {
+		r := (size - 1) / 2
+		boxRows(tmp.pix, blurred, p.w, p.h, r)
+		boxColumns(blurred, tmp.pix, p.w, p.h, r)
+	}
+	for i, v := range p.pix {
+		p.pix[i] = v + f.amount*(v-blurred[i])
+	}
+	p.store(canvas)
+	return nil
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,173 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// grayNRGBA returns an opaque gray image filled by f.
+func grayNRGBA(w, h int, f func(x, y int) uint8) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := f(x, y)
+			m.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return m
+}
+
+func TestFilterUniformImage(t *testing.T) {
+	filters := map[string]FilterDrawer{
+		"motion":  NewMotionBlur(30, 7),
+		"median":  NewMedianFilter(1),
+		"box":     NewBoxBlur(2),
+		"sharpen": NewSharpen(1.5, 1),
+		"blur":    NewBlurFilter(NewBoxGaussianBlur(3), 0, 1),
+	}
+	for name, f := range filters {
+		t.Run(name, func(t *testing.T) {
+			m := grayNRGBA(20, 10, func(x, y int) uint8 { return 100 })
+			if err := f.DrawFilter(m); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(m.Pix); i += 4 {
+				if m.Pix[i] != 100 || m.Pix[i+3] != 255 {
+					t.Fatalf("pixel %d = %v, want unchanged", i/4, m.Pix[i:i+4])
+				}
+			}
+		})
+	}
+}
+
+func TestMotionBlurDirection(t *testing.T) {
+	// 单个亮点在水平运动模糊后只在水平方向扩散
+	m := grayNRGBA(21, 21, func(x, y int) uint8 {
+		if x == 10 && y == 10 {
+			return 250
+		}
+		return 0
+	})
+	NewMotionBlur(0, 5).DrawFilter(m)
+	for x := 8; x <= 12; x++ {
+		if got := m.NRGBAAt(x, 10).R; got != 50 {
+			t.Errorf("pixel (%d, 10) = %d, want 50", x, got)
+		}
+	}
+	if m.NRGBAAt(7, 10).R != 0 || m.NRGBAAt(10, 9).R != 0 || m.NRGBAAt(10, 11).R != 0 {
+		t.Error("horizontal motion blur spread outside the row")
+	}
+
+	m = grayNRGBA(21, 21, func(x, y int) uint8 {
+		if x == 10 && y == 10 {
+			return 250
+		}
+		return 0
+	})
+	NewMotionBlur(90, 5).DrawFilter(m)
+	if m.NRGBAAt(10, 8).R != 50 || m.NRGBAAt(9, 10).R != 0 {
+		t.Error("vertical motion blur did not spread along the column")
+	}
+}
+
+func TestMedianFilterRemovesSaltAndPepper(t *testing.T) {
+	m := grayNRGBA(10, 10, func(x, y int) uint8 {
+		switch {
+		case x == 3 && y == 3:
+			return 255
+		case x == 6 && y == 6:
+			return 0
+		}
+		return 120
+	})
+	NewMedianFilter(1).DrawFilter(m)
+	if m.NRGBAAt(3, 3).R != 120 || m.NRGBAAt(6, 6).R != 120 {
+		t.Errorf("noise pixels = %d, %d, want 120", m.NRGBAAt(3, 3).R, m.NRGBAAt(6, 6).R)
+	}
+
+	// 边缘保持清晰
+	edge := grayNRGBA(10, 10, func(x, y int) uint8 {
+		if x < 5 {
+			return 0
+		}
+		return 200
+	})
+	NewMedianFilter(1).DrawFilter(edge)
+	if edge.NRGBAAt(4, 5).R != 0 || edge.NRGBAAt(5, 5).R != 200 {
+		t.Errorf("edge = %d, %d, want 0, 200", edge.NRGBAAt(4, 5).R, edge.NRGBAAt(5, 5).R)
+	}
+}
+
+func TestBoxBlurAverage(t *testing.T) {
+	m := grayNRGBA(9, 9, func(x, y int) uint8 {
+		if x == 4 && y == 4 {
+			return 225
+		}
+		return 0
+	})
+	NewBoxBlur(1).DrawFilter(m)
+	for y := 3; y <= 5; y++ {
+		for x := 3; x <= 5; x++ {
+			if got := m.NRGBAAt(x, y).R; got != 25 {
+				t.Errorf("pixel (%d, %d) = %d, want 25", x, y, got)
+			}
+		}
+	}
+	if m.NRGBAAt(2, 4).R != 0 {
+		t.Error("box blur spread beyond its radius")
+	}
+}
+
+func TestSharpenIncreasesEdgeContrast(t *testing.T) {
+	m := grayNRGBA(20, 10, func(x, y int) uint8 {
+		if x < 10 {
+			return 80
+		}
+		return 160
+	})
+	NewSharpen(1, 1).DrawFilter(m)
+	dark, light := m.NRGBAAt(9, 5).R, m.NRGBAAt(10, 5).R
+	if dark >= 80 || light <= 160 {
+		t.Errorf("edge after sharpen = %d, %d, want < 80 and > 160", dark, light)
+	}
+	if m.NRGBAAt(0, 5).R != 80 || m.NRGBAAt(19, 5).R != 160 {
+		t.Error("sharpen changed pixels far from the edge")
+	}
+}
+
+func TestCaptchaImageDrawFilter(t *testing.T) {
+	captcha := New(60, 30, color.RGBA{R: 255, G: 255, B: 255, A: 255}).
+		DrawNoise(NoiseDensityHigh, NewPointNoiseDrawer()).
+		DrawFilter(NewMedianFilter(1), NewMotionBlur(45, 5), NewSharpen(0.5, 1))
+	if captcha.Error != nil {
+		t.Errorf("DrawFilter() error = %v", captcha.Error)
+	}
+}
+
+func TestFilterContextCancelled(t *testing.T) {
+	src := randomNRGBA(60, 30, 1)
+	filters := map[string]FilterDrawer{
+		"median":   NewMedianFilter(1),
+		"motion":   NewMotionBlur(30, 5),
+		"gaussian": NewBlurFilter(NewGaussianBlur(), 5, 1),
+		"box":      NewBlurFilter(NewBoxGaussianBlur(3), 0, 2),
+	}
+	for name, f := range filters {
+		t.Run(name, func(t *testing.T) {
+			m := image.NewNRGBA(src.Rect)
+			copy(m.Pix, src.Pix)
+			// 允许开始处理后再取消
+			err := drawFilter(newCountdownContext(1), f, m)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("drawFilter() error = %v, want %v", err, context.Canceled)
+			}
+			if !bytes.Equal(m.Pix, src.Pix) {
+				t.Error("cancelled filter modified the canvas")
+			}
+		})
+	}
+}
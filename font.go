@@ -2,6 +2,7 @@ package gocaptcha
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -19,6 +20,9 @@ var embeddedFonts embed.FS
 var DefaultFontFamily = NewFontFamily()
 var ErrNoFontsInFamily = os.ErrNotExist
 
+// ErrGlyphMissing 字体集中没有任何字体包含要绘制的字符
+var ErrGlyphMissing = errors.New("font family has no glyph for character")
+
 // SetFonts sets the default font family
 func SetFonts(fonts ...string) error {
 	for _, font := range fonts {
@@ -38,7 +42,16 @@ func SetFontPath(fontDirPath string) error {
 type FontFamily struct {
 	fonts     []string
 	fontCache *sync.Map
-	r         *rand.Rand
+	// rmu 保护 r，同一个字体族会被多个 goroutine 同时用于绘制
+	rmu sync.Mutex
+	r   *rand.Rand
+}
+
+// intn 返回 [0, n) 内的随机数，可以并发调用
+func (f *FontFamily) intn(n int) int {
+	f.rmu.Lock()
+	defer f.rmu.Unlock()
+	return f.r.Intn(n)
 }
 
 // Random returns a random font from the family
@@ -46,7 +59,7 @@ func (f *FontFamily) Random() (*truetype.Font, error) {
 	if len(f.fonts) == 0 {
 		return nil, ErrNoFontsInFamily
 	}
-	fontFile := f.fonts[f.r.Intn(len(f.fonts))]
+	fontFile := f.fonts[f.intn(len(f.fonts))]
 	if v, ok := f.fontCache.Load(fontFile); ok {
 		return v.(*truetype.Font), nil
 	}
@@ -59,13 +72,9 @@ func (f *FontFamily) Random() (*truetype.Font, error) {
 }
 
 func (f *FontFamily) parseFont(fontFile string) (*truetype.Font, error) {
-	// 统一使用正斜杠，将反斜杠转换为正斜杠
-	fontFile = filepath.ToSlash(fontFile)
-
-	fontBytes, err := embeddedFonts.ReadFile(fontFile)
+	fontBytes, err := readFontFile(fontFile)
 	if err != nil {
-		// 添加更详细的错误信息
-		return nil, fmt.Errorf("failed to read font file %s: %w", fontFile, err)
+		return nil, err
 	}
 	font, err := freetype.ParseFont(fontBytes)
 	if err != nil {
@@ -74,6 +83,24 @@ func (f *FontFamily) parseFont(fontFile string) (*truetype.Font, error) {
 	return font, nil
 }
 
+// readFontFile reads a font from the embedded fonts, or from disk if it is not embedded
+func readFontFile(fontFile string) ([]byte, error) {
+	// 统一使用正斜杠，将反斜杠转换为正斜杠
+	fontFile = filepath.ToSlash(fontFile)
+
+	fontBytes, err := embeddedFonts.ReadFile(fontFile)
+	if err != nil {
+		// 内嵌字体中不存在时从磁盘读取，用于加载 CJK 等较大的字体
+		var diskErr error
+		fontBytes, diskErr = os.ReadFile(filepath.FromSlash(fontFile))
+		if diskErr != nil {
+			// 添加更详细的错误信息
+			return nil, fmt.Errorf("failed to read font file %s: %w", fontFile, err)
+		}
+	}
+	return fontBytes, nil
+}
+
 // AddFont adds a font to the family and returns an error if it fails
 func (f *FontFamily) AddFont(fontFile string) error {
 	if _, ok := f.fontCache.Load(fontFile); ok {
@@ -88,6 +115,81 @@ func (f *FontFamily) AddFont(fontFile string) error {
 	return nil
 }
 
+// AddFontBytes adds a font parsed from fontBytes to the family under the given name
+func (f *FontFamily) AddFontBytes(name string, fontBytes []byte) error {
+	if _, ok := f.fontCache.Load(name); ok {
+		return nil
+	}
+	font, err := freetype.ParseFont(fontBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse font %s: %w", name, err)
+	}
+	f.fonts = append(f.fonts, name)
+	f.fontCache.Store(name, font)
+	return nil
+}
+
+// AddFontSubset adds a font to the family keeping only the glyphs needed for charset,
+// which greatly reduces the memory used by large CJK or Unicode fonts
+func (f *FontFamily) AddFontSubset(fontFile string, charset []rune) error {
+	if _, ok := f.fontCache.Load(fontFile); ok {
+		return nil
+	}
+	fontBytes, err := readFontFile(fontFile)
+	if err != nil {
+		return err
+	}
+	subset, err := SubsetFont(fontBytes, charset)
+	if err != nil {
+		return fmt.Errorf("failed to subset font file %s: %w", fontFile, err)
+	}
+	return f.AddFontBytes(fontFile, subset)
+}
+
+// RandomFor returns a random font of the family that contains a glyph for r.
+// It falls back to Random when no font in the family has the glyph.
+func (f *FontFamily) RandomFor(r rune) (*truetype.Font, error) {
+	// 先计数再按序号取出，避免每个字符分配一个候选切片
+	n := 0
+	for _, fontFile := range f.fonts {
+		if font, ok := f.cachedFont(fontFile); ok && font.Index(r) != 0 {
+			n++
+		}
+	}
+	if n == 0 {
+		return f.Random()
+	}
+	k := f.intn(n)
+	for _, fontFile := range f.fonts {
+		if font, ok := f.cachedFont(fontFile); ok && font.Index(r) != 0 {
+			if k == 0 {
+				return font, nil
+			}
+			k--
+		}
+	}
+	return f.Random()
+}
+
+// cachedFont returns the parsed font of fontFile if it is loaded
+func (f *FontFamily) cachedFont(fontFile string) (*truetype.Font, bool) {
+	v, ok := f.fontCache.Load(fontFile)
+	if !ok {
+		return nil, false
+	}
+	return v.(*truetype.Font), true
+}
+
+// HasGlyph reports whether any font of the family contains a glyph for r
+func (f *FontFamily) HasGlyph(r rune) bool {
+	for _, fontFile := range f.fonts {
+		if v, ok := f.fontCache.Load(fontFile); ok && v.(*truetype.Font).Index(r) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // AddFontPath adds all .ttf files from the given directory to the font family and returns an error if any
 func (f *FontFamily) AddFontPath(dirPath string) error {
 	return filepath.Walk(dirPath, func(path string, info os.FileInfo, walkErr error) error {
@@ -101,12 +203,23 @@ func (f *FontFamily) AddFontPath(dirPath string) error {
 	})
 }
 
-// NewFontFamily creates a new font family with the embedded fonts
-func NewFontFamily() *FontFamily {
+// NewCustomFontFamily creates a font family that contains only the given fonts
+func NewCustomFontFamily(fonts ...string) (*FontFamily, error) {
 	ff := &FontFamily{
 		fontCache: &sync.Map{},
 		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	for _, font := range fonts {
+		if err := ff.AddFont(font); err != nil {
+			return nil, err
+		}
+	}
+	return ff, nil
+}
+
+// NewFontFamily creates a new font family with the embedded fonts
+func NewFontFamily() *FontFamily {
+	ff, _ := NewCustomFontFamily()
 
 	entries, _ := embeddedFonts.ReadDir("fonts")
 	for _, entry := range entries {
@@ -121,3 +234,19 @@ func NewFontFamily() *FontFamily {
 
 	return ff
 }
+
+// firstFor returns the first font of the family that contains a glyph for r,
+// so that text such as captions is drawn in a consistent font.
+// It returns ErrGlyphMissing when no font in the family has the glyph,
+// instead of drawing the font's .notdef box.
+func (f *FontFamily) firstFor(r rune) (*truetype.Font, error) {
+	if len(f.fonts) == 0 {
+		return nil, ErrNoFontsInFamily
+	}
+	for _, fontFile := range f.fonts {
+		if v, ok := f.fontCache.Load(fontFile); ok && v.(*truetype.Font).Index(r) != 0 {
+			return v.(*truetype.Font), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrGlyphMissing, r)
+}
@@ -0,0 +1,215 @@
+package gocaptcha
+
+import (
+	"container/list"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// DefaultGlyphCacheSize is the size in bytes of the glyph masks kept by DefaultGlyphCache.
+	// It holds every noise glyph of a 180x60 captcha and about half of those of a 400x150 one.
+	DefaultGlyphCacheSize = 16 << 20
+	// GlyphSizeQuantum is the font size step in points used to key cached glyphs.
+	// Sizes are rounded to the nearest step so randomized sizes still share masks.
+	GlyphSizeQuantum = 0.5
+)
+
+var ErrGlyphUnavailable = errors.New("glyph cannot be rendered")
+
+// DefaultGlyphCache is the glyph cache shared by the text and noise drawers.
+var DefaultGlyphCache = NewGlyphCache(DefaultGlyphCacheSize)
+
+type glyphKey struct {
+	font *truetype.Font
+	r    rune
+	// size is the font size in units of GlyphSizeQuantum.
+	size int
+	dpi  float64
+}
+
+// glyphMask is a rasterized glyph. The mask is placed at dot+offset for a glyph
+// drawn with its baseline origin at dot.
+type glyphMask struct {
+	mask    *image.Alpha
+	offset  image.Point
+	advance fixed.Int26_6
+}
+
+type glyphEntry struct {
+	key   glyphKey
+	glyph *glyphMask
+	size  int
+}
+
+// glyphEntryOverhead approximates the memory used by an entry besides the mask pixels.
+const glyphEntryOverhead = 160
+
+// GlyphCacheStats are the counters of a GlyphCache.
+type GlyphCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Len is the number of cached glyphs.
+	Len int
+	// Size is the approximate memory used by the cached glyphs in bytes.
+	Size int
+	// Capacity is the maximum Size in bytes.
+	Capacity int
+}
+
+// HitRate returns the fraction of lookups served from the cache, 0 when there were none.
+func (s GlyphCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// GlyphCache is an LRU cache of rasterized glyph masks keyed by font, rune,
+// quantized size and DPI, bounded by the memory used by the masks.
+// It is safe for concurrent use.
+type GlyphCache struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	ll       *list.List
+	items    map[glyphKey]*list.Element
+	stats    GlyphCacheStats
+}
+
+// NewGlyphCache returns a cache holding glyph masks up to capacity bytes.
+// A capacity of 0 disables caching, every glyph is rasterized on demand.
+func NewGlyphCache(capacity int) *GlyphCache {
+	return &GlyphCache{
+		capacity: max(capacity, 0),
+		ll:       list.New(),
+		items:    make(map[glyphKey]*list.Element),
+	}
+}
+
+// Stats returns the current counters.
+func (c *GlyphCache) Stats() GlyphCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Len = c.ll.Len()
+	s.Size = c.size
+	s.Capacity = c.capacity
+	return s
+}
+
+// SetCapacity changes the capacity, evicting the least recently used glyphs if needed.
+func (c *GlyphCache) SetCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = max(capacity, 0)
+	c.evict()
+}
+
+// Purge removes all glyphs and resets the counters.
+func (c *GlyphCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.size = 0
+	c.items = make(map[glyphKey]*list.Element)
+	c.stats = GlyphCacheStats{}
+}
+
+// evict removes the least recently used glyphs above the capacity, c.mu must be held.
+func (c *GlyphCache) evict() {
+	for c.size > c.capacity {
+		e := c.ll.Back()
+		entry := e.Value.(*glyphEntry)
+		c.ll.Remove(e)
+		delete(c.items, entry.key)
+		c.size -= entry.size
+		c.stats.Evictions++
+	}
+}
+
+// glyph returns the mask of r in font f, rasterizing it on a miss.
+func (c *GlyphCache) glyph(f *truetype.Font, r rune, size float64, dpi float64) (*glyphMask, error) {
+	key := glyphKey{font: f, r: r, size: int(math.Round(size / GlyphSizeQuantum)), dpi: dpi}
+
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e.Value.(*glyphEntry).glyph, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// rasterize outside the lock, a concurrent miss on the same key only costs a duplicate render
+	g, err := rasterizeGlyph(f, r, float64(key.size)*GlyphSizeQuantum, dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		return e.Value.(*glyphEntry).glyph, nil
+	}
+	n := len(g.mask.Pix) + glyphEntryOverhead
+	if n <= c.capacity {
+		c.items[key] = c.ll.PushFront(&glyphEntry{key: key, glyph: g, size: n})
+		c.size += n
+		c.evict()
+	}
+	return g, nil
+}
+
+// rasterizeGlyph renders r with full hinting, the same as freetype.Context.
+func rasterizeGlyph(f *truetype.Font, r rune, size float64, dpi float64) (*glyphMask, error) {
+	// the face keeps its own mask buffer for every cache entry, one is enough here
+	face := truetype.NewFace(f, &truetype.Options{Size: size, DPI: dpi, Hinting: font.HintingFull, GlyphCacheEntries: 1})
+	dr, mask, maskp, advance, ok := face.Glyph(fixed.Point26_6{}, r)
+	if !ok {
+		return nil, ErrGlyphUnavailable
+	}
+	m := image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	draw.Draw(m, m.Bounds(), mask, maskp, draw.Src)
+	return &glyphMask{mask: m, offset: dr.Min, advance: advance}, nil
+}
+
+// drawString composites text from src onto dst with the baseline origin of the
+// first glyph at dot, and returns the dot after the last glyph.
+func (c *GlyphCache) drawString(dst draw.Image, f *truetype.Font, size float64, dpi float64, dot image.Point, text string, src image.Image) (image.Point, error) {
+	x := fixed.I(dot.X)
+	for _, r := range text {
+		advance, err := c.drawRune(dst, f, size, dpi, image.Pt(x.Round(), dot.Y), r, src)
+		if err != nil {
+			return dot, err
+		}
+		x += advance
+	}
+	return image.Pt(x.Round(), dot.Y), nil
+}
+
+// drawRune composites r from src onto dst with its baseline origin at dot and returns
+// its advance. Drawers pass a single *image.Uniform whose color they update per glyph,
+// so drawing a glyph does not allocate.
+func (c *GlyphCache) drawRune(dst draw.Image, f *truetype.Font, size float64, dpi float64, dot image.Point, r rune, src image.Image) (fixed.Int26_6, error) {
+	g, err := c.glyph(f, r, size, dpi)
+	if err != nil {
+		return 0, err
+	}
+	p := dot.Add(g.offset)
+	draw.DrawMask(dst, g.mask.Rect.Add(p), src, image.Point{}, g.mask, image.Point{}, draw.Over)
+	return g.advance, nil
+}
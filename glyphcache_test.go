@@ -0,0 +1,228 @@
+package gocaptcha
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+)
+
+// glyphEntrySize returns the bytes a glyph takes in a GlyphCache.
+func glyphEntrySize(t *testing.T, f *truetype.Font, r rune) int {
+	g, err := rasterizeGlyph(f, r, 24, DefaultDPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(g.mask.Pix) + glyphEntryOverhead
+}
+
+func TestGlyphCacheLRU(t *testing.T) {
+	f, err := DefaultFontFamily.firstFor('A')
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := glyphEntrySize(t, f, 'A'), glyphEntrySize(t, f, 'B'), glyphEntrySize(t, f, 'C')
+	// room for A with either B or C, but not all three
+	cache := NewGlyphCache(max(a+b, a+c))
+	for _, r := range "ABAC" {
+		if _, err = cache.glyph(f, r, 24, DefaultDPI); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := GlyphCacheStats{Hits: 1, Misses: 3, Evictions: 1, Len: 2, Size: a + c, Capacity: max(a+b, a+c)}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	// B was the least recently used glyph, A and C are still cached
+	for _, r := range "AC" {
+		cache.glyph(f, r, 24, DefaultDPI)
+	}
+	if got := cache.Stats(); got.Hits != 3 {
+		t.Errorf("Stats().Hits = %d, want 3", got.Hits)
+	}
+
+	cache.SetCapacity(c)
+	if got := cache.Stats(); got.Len != 1 || got.Size != c || got.Evictions != 2 {
+		t.Errorf("Stats() after SetCapacity = %+v, want only C cached", got)
+	}
+	cache.Purge()
+	if got := cache.Stats(); got != (GlyphCacheStats{Capacity: c}) {
+		t.Errorf("Stats() after Purge = %+v", got)
+	}
+}
+
+func TestGlyphCacheQuantizesSize(t *testing.T) {
+	f, err := DefaultFontFamily.Random()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewGlyphCache(1 << 20)
+	a, _ := c.glyph(f, 'x', 30.1, DefaultDPI)
+	b, _ := c.glyph(f, 'x', 29.9, DefaultDPI)
+	if a != b {
+		t.Error("sizes within GlyphSizeQuantum were cached separately")
+	}
+	c.glyph(f, 'x', 30.5, DefaultDPI)
+	c.glyph(f, 'x', 30, 96)
+	if got := c.Stats(); got.Hits != 1 || got.Len != 3 {
+		t.Errorf("Stats() = %+v, want 1 hit and 3 glyphs", got)
+	}
+}
+
+func TestGlyphCacheDisabled(t *testing.T) {
+	f, err := DefaultFontFamily.Random()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewGlyphCache(0)
+	c.glyph(f, 'x', 30, DefaultDPI)
+	c.glyph(f, 'x', 30, DefaultDPI)
+	if got := c.Stats(); got.Hits != 0 || got.Misses != 2 || got.Len != 0 {
+		t.Errorf("Stats() = %+v, want only misses", got)
+	}
+}
+
+// TestGlyphCacheMatchesFreetype checks that compositing cached masks gives the same
+// pixels as drawing the string with freetype.Context.
+func TestGlyphCacheMatchesFreetype(t *testing.T) {
+	textColor := color.RGBA{R: 20, G: 60, B: 120, A: 255}
+	for i, fontFile := range DefaultFontFamily.fonts {
+		f, err := DefaultFontFamily.parseFont(fontFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []float64{18, 30.5} {
+			got := image.NewRGBA(image.Rect(0, 0, 160, 60))
+			want := image.NewRGBA(got.Rect)
+			dot := image.Pt(5+i, 42)
+			if _, err = NewGlyphCache(1<<20).drawString(got, f, size, DefaultDPI, dot, "Ag7", image.NewUniform(textColor)); err != nil {
+				t.Fatal(err)
+			}
+			c := freetype.NewContext()
+			c.SetDPI(DefaultDPI)
+			c.SetClip(want.Bounds())
+			c.SetDst(want)
+			c.SetHinting(font.HintingFull)
+			c.SetSrc(image.NewUniform(textColor))
+			c.SetFontSize(size)
+			c.SetFont(f)
+			// freetype applies kerning between glyphs, compare glyph by glyph
+			x := dot.X
+			for _, r := range "Ag7" {
+				p, err := c.DrawString(string(r), freetype.Pt(x, dot.Y))
+				if err != nil {
+					t.Fatal(err)
+				}
+				x = p.X.Round()
+			}
+			if d, _ := maxPixelDiff(got, want); d != 0 {
+				t.Errorf("%s size %v: cached glyphs differ from freetype by %d", fontFile, size, d)
+			}
+		}
+	}
+}
+
+// referenceTextNoise is the previous textNoiseDrawer that rasterized every glyph
+// with a new freetype.Context.
+func referenceTextNoise(r *rand.Rand, img draw.Image) error {
+	bounds := img.Bounds()
+	maxSize := (bounds.Dy() * bounds.Dx()) / 1000
+	c := freetype.NewContext()
+	c.SetDPI(DefaultDPI)
+	c.SetClip(bounds)
+	c.SetDst(img)
+	c.SetHinting(font.HintingFull)
+	rawFontSize := float64(bounds.Dy()) / (1 + float64(r.Intn(7))/float64(10))
+	for i := 0; i < maxSize; i++ {
+		rw := r.Intn(bounds.Dx())
+		rh := r.Intn(bounds.Dy())
+		text := RandText(1)
+		fontSize := rawFontSize/2 + float64(r.Intn(5))
+		c.SetSrc(image.NewUniform(RandLightColor()))
+		c.SetFontSize(fontSize)
+		f, err := DefaultFontFamily.Random()
+		if err != nil {
+			return err
+		}
+		c.SetFont(f)
+		if _, err = c.DrawString(text, freetype.Pt(rw, rh)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withGlyphCache runs fn with DefaultGlyphCache replaced by cache.
+func withGlyphCache(cache *GlyphCache, fn func()) {
+	old := DefaultGlyphCache
+	DefaultGlyphCache = cache
+	defer func() { DefaultGlyphCache = old }()
+	fn()
+}
+
+func BenchmarkTextNoise(b *testing.B) {
+	for _, size := range pixelBenchSizes {
+		canvas := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		b.Run(fmt.Sprintf("reference/%dx%d", size.X, size.Y), func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < b.N; i++ {
+				if err := referenceTextNoise(r, canvas); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		drawer := &textNoiseDrawer{r: rand.New(rand.NewSource(1)), dpi: DefaultDPI}
+		b.Run(fmt.Sprintf("uncached/%dx%d", size.X, size.Y), func(b *testing.B) {
+			withGlyphCache(NewGlyphCache(0), func() {
+				for i := 0; i < b.N; i++ {
+					if err := drawer.DrawNoise(canvas, NoiseDensityHigh); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+		b.Run(fmt.Sprintf("cached/%dx%d", size.X, size.Y), func(b *testing.B) {
+			cache := NewGlyphCache(DefaultGlyphCacheSize)
+			withGlyphCache(cache, func() {
+				// 预热到稳定状态
+				for i := 0; i < 2000; i++ {
+					drawer.DrawNoise(canvas, NoiseDensityHigh)
+				}
+				warm := cache.Stats()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := drawer.DrawNoise(canvas, NoiseDensityHigh); err != nil {
+						b.Fatal(err)
+					}
+				}
+				s := cache.Stats()
+				b.ReportMetric(float64(s.Hits-warm.Hits)/float64(s.Hits+s.Misses-warm.Hits-warm.Misses), "hit-rate")
+			})
+		})
+	}
+}
+
+func BenchmarkGenerateCaptchaHard(b *testing.B) {
+	for _, size := range pixelBenchSizes {
+		for _, mode := range []struct {
+			name  string
+			cache *GlyphCache
+		}{{"uncached", NewGlyphCache(0)}, {"cached", NewGlyphCache(DefaultGlyphCacheSize)}} {
+			b.Run(fmt.Sprintf("%s/%dx%d", mode.name, size.X, size.Y), func(b *testing.B) {
+				withGlyphCache(mode.cache, func() {
+					for i := 0; i < b.N; i++ {
+						if _, _, err := GenerateCaptcha(size.X, size.Y, 4, CaptchaHard); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			})
+		}
+	}
+}
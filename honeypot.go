@@ -0,0 +1,286 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHoneypotFields 默认渲染的隐藏字段数
+	DefaultHoneypotFields = 2
+	// DefaultMinFormTime 默认的最短填表时间
+	DefaultMinFormTime = 3 * time.Second
+	// DefaultMaxFormAge 表单的最长有效期
+	DefaultMaxFormAge = time.Hour
+	// HoneypotTokenField 保存签名时间戳和令牌 nonce 的表单字段
+	HoneypotTokenField = "_hp"
+	// DefaultRiskThreshold 风险分数达到该值时需要展示图片验证码
+	DefaultRiskThreshold = 0.5
+)
+
+var (
+	ErrHoneypotFilled   = errors.New("honeypot field filled")
+	ErrFormTooFast      = errors.New("form submitted too fast")
+	ErrFormExpired      = errors.New("form expired")
+	ErrInvalidFormToken = errors.New("invalid form token")
+)
+
+// 风险信号名称
+const (
+	SignalHoneypot     = "honeypot"
+	SignalFormTooFast  = "form_too_fast"
+	SignalFormExpired  = "form_expired"
+	SignalFormInvalid  = "form_invalid"
+	SignalSolveTooFast = "solve_too_fast"
+	SignalSolveUniform = "solve_uniform"
+)
+
+// DefaultRiskWeights 各风险信号的默认权重，取值范围 0~1
+var DefaultRiskWeights = map[string]float64{
+	SignalHoneypot:     1,
+	SignalFormTooFast:  0.6,
+	SignalFormExpired:  0.2,
+	SignalFormInvalid:  0.8,
+	SignalSolveTooFast: 0.7,
+	SignalSolveUniform: 0.5,
+}
+
+// honeypotNames 隐藏字段名的前缀，使用机器人倾向于填写的常见名称.
+// 不使用 address2、phone2 等浏览器会自动填充的名称，避免误伤正常用户
+var honeypotNames = []string{"website", "url", "homepage", "company", "nickname"}
+
+var honeypotTemplate = template.Must(template.New("honeypot").Parse(
+	`<div style="position:absolute;left:-10000px;top:auto;width:1px;height:1px;overflow:hidden" aria-hidden="true">` +
+		`{{range .Names}}<input type="text" name="{{.}}" value="" tabindex="-1" autocomplete="off">{{end}}` +
+		`</div><input type="hidden" name="{{.TokenField}}" value="{{.Token}}">`))
+
+// Honeypot 生成隐藏的蜜罐字段和签名时间戳，用于在不打扰用户的情况下识别机器人.
+// 隐藏字段名每次随机生成并保存在 Store 中，HoneypotTokenField 字段只包含签名的时间戳和 nonce.
+// 每个令牌只能校验一次，重复提交视为无效令牌.
+type Honeypot struct {
+	// Secret 签名密钥，多实例部署时需要使用相同的密钥
+	Secret []byte
+	// Store 保存令牌对应的隐藏字段名，为空时使用有效期为 MaxFormAge 的内存存储.
+	// 多实例部署时需要使用共享的存储
+	Store Store
+	// Fields 隐藏字段数，为 0 时使用 DefaultHoneypotFields
+	Fields int
+	// MinFormTime 最短填表时间，为 0 时使用 DefaultMinFormTime
+	MinFormTime time.Duration
+	// MaxFormAge 表单有效期，为 0 时使用 DefaultMaxFormAge
+	MaxFormAge time.Duration
+
+	once sync.Once
+}
+
+// NewHoneypot 创建蜜罐，secret 为空时生成随机密钥，仅适用于单实例部署
+func NewHoneypot(secret []byte) *Honeypot {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	return &Honeypot{Secret: secret}
+}
+
+// Field 渲染隐藏字段和签名时间戳，可在 html/template 中通过 FuncMap 使用
+func (h *Honeypot) Field() (template.HTML, error) {
+	n := h.Fields
+	if n <= 0 {
+		n = DefaultHoneypotFields
+	}
+	names := make([]string, n)
+	for i := range names {
+		b := make([]byte, 1)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		names[i] = honeypotNames[int(b[0])%len(honeypotNames)] + "_" + strings.ToLower(NewCaptchaID()[:6])
+	}
+
+	nonce, err := Issue(h.store(), strings.Join(names, ","))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = honeypotTemplate.Execute(&buf, map[string]interface{}{
+		"Names":      names,
+		"TokenField": HoneypotTokenField,
+		"Token":      h.sign(time.Now(), nonce),
+	})
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// FuncMap 返回包含 "honeypot" 函数的 template.FuncMap，模板中使用 {{honeypot}}
+func (h *Honeypot) FuncMap() template.FuncMap {
+	return template.FuncMap{"honeypot": h.Field}
+}
+
+func (h *Honeypot) maxFormAge() time.Duration {
+	if h.MaxFormAge <= 0 {
+		return DefaultMaxFormAge
+	}
+	return h.MaxFormAge
+}
+
+// store 返回保存字段名的存储，未设置时创建有效期为 MaxFormAge 的内存存储
+func (h *Honeypot) store() Store {
+	h.once.Do(func() {
+		if h.Store == nil {
+			h.Store = NewMemoryStore(h.maxFormAge())
+		}
+	})
+	return h.Store
+}
+
+// sign 生成 "payload.signature" 格式的令牌，payload 为时间戳和 nonce，字段名只保存在服务端
+func (h *Honeypot) sign(issued time.Time, nonce string) string {
+	payload := strconv.FormatInt(issued.UnixNano(), 10) + ":" + nonce
+	mac := hmac.New(sha256.New, h.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse 校验令牌签名并返回签发时间和 nonce
+func (h *Honeypot) parse(token string) (time.Time, string, error) {
+	p, s, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, "", ErrInvalidFormToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidFormToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidFormToken
+	}
+	mac := hmac.New(sha256.New, h.Secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return time.Time{}, "", ErrInvalidFormToken
+	}
+	ts, nonce, _ := strings.Cut(string(payload), ":")
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || nonce == "" {
+		return time.Time{}, "", ErrInvalidFormToken
+	}
+	return time.Unix(0, nanos), nonce, nil
+}
+
+// Signals 返回提交的表单触发的全部风险信号.
+// 令牌在第一次校验时作废，再次提交同一个令牌返回 SignalFormInvalid.
+func (h *Honeypot) Signals(form url.Values) []string {
+	issued, nonce, err := h.parse(form.Get(HoneypotTokenField))
+	if err != nil {
+		return []string{SignalFormInvalid}
+	}
+	elapsed := time.Since(issued)
+	if elapsed > h.maxFormAge() {
+		// 过期的记录可能已被存储清除，无法再检查隐藏字段
+		_, _ = h.store().Get(nonce, true)
+		return []string{SignalFormExpired}
+	}
+	fields, err := h.store().Get(nonce, true)
+	if err != nil {
+		return []string{SignalFormInvalid}
+	}
+
+	var signals []string
+	for _, name := range strings.Split(fields, ",") {
+		if form.Get(name) != "" {
+			signals = append(signals, SignalHoneypot)
+			break
+		}
+	}
+	minFormTime := h.MinFormTime
+	if minFormTime <= 0 {
+		minFormTime = DefaultMinFormTime
+	}
+	if elapsed < minFormTime {
+		signals = append(signals, SignalFormTooFast)
+	}
+	return signals
+}
+
+// Verify 校验提交的表单，蜜罐字段被填写、提交过快或令牌已使用时返回错误
+func (h *Honeypot) Verify(form url.Values) error {
+	for _, signal := range h.Signals(form) {
+		switch signal {
+		case SignalFormInvalid:
+			return ErrInvalidFormToken
+		case SignalHoneypot:
+			return ErrHoneypotFilled
+		case SignalFormTooFast:
+			return ErrFormTooFast
+		case SignalFormExpired:
+			return ErrFormExpired
+		}
+	}
+	return nil
+}
+
+// RiskScore 组合多个风险信号得到的风险分数.
+// 各信号视为相互独立，分数为 1-∏(1-权重)，取值范围 0~1.
+type RiskScore struct {
+	// Weights 信号权重，为空时使用 DefaultRiskWeights
+	Weights map[string]float64
+	// Signals 已触发的信号
+	Signals []string
+}
+
+// Add 添加风险信号，未知信号的权重为 0
+func (r *RiskScore) Add(signals ...string) *RiskScore {
+	r.Signals = append(r.Signals, signals...)
+	return r
+}
+
+// AddTiming 根据验证码的解题耗时添加风险信号
+func (r *RiskScore) AddTiming(t SolveTiming) *RiskScore {
+	if t.TooFast {
+		r.Add(SignalSolveTooFast)
+	}
+	if t.Suspicious {
+		r.Add(SignalSolveUniform)
+	}
+	return r
+}
+
+// Score 返回风险分数，同一信号只计算一次
+func (r *RiskScore) Score() float64 {
+	weights := r.Weights
+	if weights == nil {
+		weights = DefaultRiskWeights
+	}
+	seen := make(map[string]bool, len(r.Signals))
+	safe := 1.0
+	for _, signal := range r.Signals {
+		if seen[signal] {
+			continue
+		}
+		seen[signal] = true
+		safe *= 1 - math.Max(0, math.Min(1, weights[signal]))
+	}
+	return 1 - safe
+}
+
+// Suspicious 风险分数达到 DefaultRiskThreshold 时返回 true，此时应展示图片验证码
+func (r *RiskScore) Suspicious() bool {
+	return r.Score() >= DefaultRiskThreshold
+}
@@ -0,0 +1,149 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var honeypotInputPattern = regexp.MustCompile(`<input type="(text|hidden)" name="([^"]+)" value="([^"]*)"`)
+
+// parseHoneypotForm 从渲染结果中取出字段，模拟浏览器提交的表单
+func parseHoneypotForm(t *testing.T, html template.HTML) (form url.Values, honeypots []string) {
+	t.Helper()
+	form = url.Values{}
+	for _, m := range honeypotInputPattern.FindAllStringSubmatch(string(html), -1) {
+		form.Set(m[2], m[3])
+		if m[1] == "text" {
+			honeypots = append(honeypots, m[2])
+		}
+	}
+	if form.Get(HoneypotTokenField) == "" {
+		t.Fatalf("rendered field has no token: %s", html)
+	}
+	return form, honeypots
+}
+
+func TestHoneypotTemplate(t *testing.T) {
+	h := NewHoneypot([]byte("secret"))
+	tpl := template.Must(template.New("form").Funcs(h.FuncMap()).Parse(`<form>{{honeypot}}</form>`))
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, nil); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	_, honeypots := parseHoneypotForm(t, template.HTML(buf.String()))
+	if len(honeypots) != DefaultHoneypotFields {
+		t.Errorf("rendered %d honeypot fields, want %d", len(honeypots), DefaultHoneypotFields)
+	}
+
+	// 每次渲染的字段名都不相同
+	html, _ := h.Field()
+	_, again := parseHoneypotForm(t, html)
+	if again[0] == honeypots[0] {
+		t.Errorf("honeypot names repeated: %v", again)
+	}
+}
+
+func TestHoneypotVerify(t *testing.T) {
+	h := &Honeypot{Secret: []byte("secret"), MinFormTime: 20 * time.Millisecond, MaxFormAge: time.Minute}
+	render := func() (url.Values, []string) {
+		html, err := h.Field()
+		if err != nil {
+			t.Fatalf("Field() error = %v", err)
+		}
+		return parseHoneypotForm(t, html)
+	}
+
+	form, _ := render()
+	if err := h.Verify(form); err != ErrFormTooFast {
+		t.Errorf("Verify() error = %v, want %v", err, ErrFormTooFast)
+	}
+	form, _ = render()
+	time.Sleep(30 * time.Millisecond)
+	if err := h.Verify(form); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	// 令牌只能使用一次
+	if err := h.Verify(form); err != ErrInvalidFormToken {
+		t.Errorf("Verify() of replayed form error = %v, want %v", err, ErrInvalidFormToken)
+	}
+
+	filled, honeypots := render()
+	filled.Set(honeypots[1], "http://spam.example")
+	if err := h.Verify(filled); err != ErrHoneypotFilled {
+		t.Errorf("Verify() with filled honeypot error = %v, want %v", err, ErrHoneypotFilled)
+	}
+
+	form, _ = render()
+	if err := (&Honeypot{Secret: []byte("other"), Store: h.Store}).Verify(form); err != ErrInvalidFormToken {
+		t.Errorf("Verify() with other secret error = %v, want %v", err, ErrInvalidFormToken)
+	}
+	tampered := url.Values{HoneypotTokenField: {strings.Replace(form.Get(HoneypotTokenField), "A", "B", 1) + "x"}}
+	if err := h.Verify(tampered); err != ErrInvalidFormToken {
+		t.Errorf("Verify() with tampered token error = %v, want %v", err, ErrInvalidFormToken)
+	}
+	if err := h.Verify(url.Values{}); err != ErrInvalidFormToken {
+		t.Errorf("Verify() without token error = %v, want %v", err, ErrInvalidFormToken)
+	}
+
+	form, _ = render()
+	time.Sleep(2 * time.Millisecond)
+	expired := &Honeypot{Secret: []byte("secret"), Store: h.Store, MinFormTime: time.Nanosecond, MaxFormAge: time.Millisecond}
+	if err := expired.Verify(form); err != ErrFormExpired {
+		t.Errorf("Verify() of old form error = %v, want %v", err, ErrFormExpired)
+	}
+}
+
+func TestHoneypotTokenHidesFields(t *testing.T) {
+	h := NewHoneypot([]byte("secret"))
+	html, err := h.Field()
+	if err != nil {
+		t.Fatalf("Field() error = %v", err)
+	}
+	form, honeypots := parseHoneypotForm(t, html)
+	payload, _, _ := strings.Cut(form.Get(HoneypotTokenField), ".")
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range honeypots {
+		if strings.Contains(string(decoded), name) {
+			t.Errorf("token %q exposes honeypot field %q", decoded, name)
+		}
+	}
+}
+
+func TestRiskScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		signals    []string
+		timing     SolveTiming
+		want       float64
+		suspicious bool
+	}{
+		{name: "clean", want: 0},
+		{name: "honeypot", signals: []string{SignalHoneypot}, want: 1, suspicious: true},
+		{name: "expired only", signals: []string{SignalFormExpired}, want: 0.2},
+		{name: "combined", signals: []string{SignalFormExpired, SignalSolveUniform}, want: 0.6, suspicious: true},
+		{name: "duplicates", signals: []string{SignalFormExpired, SignalFormExpired}, want: 0.2},
+		{name: "timing", timing: SolveTiming{TooFast: true}, want: 0.7, suspicious: true},
+		{name: "unknown", signals: []string{"unknown"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := (&RiskScore{}).Add(tt.signals...).AddTiming(tt.timing)
+			if got := r.Score(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+			if got := r.Suspicious(); got != tt.suspicious {
+				t.Errorf("Suspicious() = %v, want %v", got, tt.suspicious)
+			}
+		})
+	}
+}
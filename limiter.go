@@ -0,0 +1,112 @@
+package gocaptcha
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError 超出频率限制时返回的错误，errors.Is(err, ErrRateLimited) 为 true
+type RateLimitError struct {
+	// RetryAfter 需要等待的时间
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limited, retry after " + e.RetryAfter.String()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// Limiter 按 key 限制操作频率的接口，可替换为 Redis 等外部实现
+type Limiter interface {
+	// Allow 消耗 key 的一次配额，配额不足时返回需要等待的时间
+	Allow(key string) (ok bool, retryAfter time.Duration)
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type tokenBucketLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// Allow 按令牌桶算法消耗一个令牌，并顺带清理已经回满的桶
+func (l *tokenBucketLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) > full {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > full {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// NewRateLimiter 创建一个内存令牌桶限流器，每个 key 每秒补充 rate 个令牌，最多累积 burst 个
+func NewRateLimiter(rate float64, burst int) Limiter {
+	if rate <= 0 {
+		rate = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucketLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow 在 limiter 不为空时消耗 key 的配额，超出限制时返回 *RateLimitError
+func allow(limiter Limiter, key string) error {
+	if limiter == nil {
+		return nil
+	}
+	if ok, retryAfter := limiter.Allow(key); !ok {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// ClientIP 返回请求的远端 IP，作为默认的客户端 key.
+// 位于反向代理之后时应自行从可信的请求头中取得客户端 IP.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
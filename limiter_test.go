@@ -0,0 +1,61 @@
+package gocaptcha
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(10, 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("Allow() #%d = false within burst", i)
+		}
+	}
+	ok, retryAfter := l.Allow("a")
+	if ok {
+		t.Fatal("Allow() = true after burst is exhausted")
+	}
+	if retryAfter <= 0 || retryAfter > 100*time.Millisecond {
+		t.Errorf("retryAfter = %v, want (0, 100ms]", retryAfter)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("Allow() limited an unrelated key")
+	}
+	time.Sleep(retryAfter + 10*time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("Allow() = false after the bucket refilled")
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	err := allow(NewRateLimiter(1, 1), "a")
+	if err != nil {
+		t.Fatalf("allow() error = %v", err)
+	}
+	limiter := NewRateLimiter(1, 1)
+	limiter.Allow("a")
+	err = allow(limiter, "a")
+	var rateErr *RateLimitError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rateErr) || rateErr.RetryAfter <= 0 {
+		t.Errorf("allow() error = %v, want *RateLimitError", err)
+	}
+}
+
+func TestPoWHandlerRateLimited(t *testing.T) {
+	h := &PoWHandler{Store: NewMemoryStore(DefaultExpiration), Bits: 1, Limiter: NewRateLimiter(0.5, 1)}
+	req := httptest.NewRequest(http.MethodGet, "/pow", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("second request status = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
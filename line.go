@@ -44,16 +44,18 @@ func (beeline) DrawLine(canvas draw.Image, x1 image.Point, y1 image.Point, color
 	}
 
 	// 边界检查函数
+	width, height := canvas.Bounds().Dx(), canvas.Bounds().Dy()
 	isValidPoint := func(cx, cy int) bool {
-		return cx >= 0 && cx < canvas.Bounds().Dx() && cy >= 0 && cy < canvas.Bounds().Dy()
+		return cx >= 0 && cx < width && cy >= 0 && cy < height
 	}
+	setter := newPixelSetter(canvas, color)
 
 	// 绘制粗点函数
 	drawThickPoint := func(cx, cy int) {
 		for _, offset := range offsets {
 			nx, ny := cx+offset.dx, cy+offset.dy
 			if isValidPoint(nx, ny) {
-				canvas.Set(nx, ny, color)
+				setter.set(nx, ny)
 			}
 		}
 	}
@@ -107,12 +109,13 @@ func (c curveLine) DrawLine(canvas draw.Image, x image.Point, y image.Point, cl
 	px1 := 0
 	px2 := int(Random(int64(float64(canvas.Bounds().Dx())*0.8), int64(canvas.Bounds().Dx())))
 
+	setter := newPixelSetter(canvas, cl)
 	for px = px1; px < px2; px++ {
 		if phase != 0 {
 			py = float64(amplitude)*math.Sin(phase*float64(px)+frequency) + b + (float64(canvas.Bounds().Dx()) / float64(5))
 			i := canvas.Bounds().Dy() / 5
 			for i > 0 {
-				canvas.Set(px+i, int(py), cl)
+				setter.set(px+i, int(py))
 				i--
 			}
 		}
@@ -141,10 +144,11 @@ func (b bezierLine) DrawLine(canvas draw.Image, p0 image.Point, p2 image.Point,
 	p3 := image.Point{X: width - 1, Y: b.r.Intn(height)}
 
 	// 绘制贝塞尔曲线
+	setter := newPixelSetter(canvas, curveColor)
 	for t := 0.0; t <= 1.0; t += 0.001 {
 		x := int((1-t)*(1-t)*(1-t)*float64(p0.X) + 3*(1-t)*(1-t)*t*float64(p1.X) + 3*(1-t)*t*t*float64(p2.X) + t*t*t*float64(p3.X))
 		y := int((1-t)*(1-t)*(1-t)*float64(p0.Y) + 3*(1-t)*(1-t)*t*float64(p1.Y) + 3*(1-t)*t*t*float64(p2.Y) + t*t*t*float64(p3.Y))
-		canvas.Set(x, y, curveColor)
+		setter.set(x, y)
 	}
 	return nil
 }
@@ -170,14 +174,19 @@ func (b bezier3DLine) DrawLine(canvas draw.Image, p0 image.Point, p2 image.Point
 	//p2 := image.Point{X: width/2 + b.r.Intn(width/4), Y: b.r.Intn(height)}
 	p3 := image.Point{X: width - 1, Y: b.r.Intn(height)}
 
-	drawPointWithWidth := func(img draw.Image, x, y int, col color.Color, width int) {
-		for dx := -width; dx <= width; dx++ {
-			for dy := -width; dy <= width; dy++ {
-				// 确保点在圆形范围内
-				if dx*dx+dy*dy <= width*width {
-					img.Set(x+dx, y+dy, col)
-				}
+	setter := newNRGBAPixelSetter(canvas, color.NRGBA{})
+	drawPointWithWidth := func(x, y int, col color.NRGBA, width int) {
+		setter.setNRGBA(col)
+		// 按行填充圆形范围内的像素，half 为每一行的半宽
+		half := 0
+		for dy := -width; dy <= width; dy++ {
+			for half < width && (half+1)*(half+1)+dy*dy <= width*width {
+				half++
 			}
+			for half > 0 && half*half+dy*dy > width*width {
+				half--
+			}
+			setter.fillSpan(x-half, x+half, y+dy)
 		}
 	}
 	w := float64(b.r.Intn(height / 5))
@@ -198,7 +207,7 @@ func (b bezier3DLine) DrawLine(canvas draw.Image, p0 image.Point, p2 image.Point
 
 		// 模拟线宽，绘制当前点周围的像素
 		lineWidth := int(w * (1 - t)) // 线宽随 t 减小
-		drawPointWithWidth(canvas, x, y, lineColor, lineWidth)
+		drawPointWithWidth(x, y, lineColor, lineWidth)
 	}
 	return nil
 }
@@ -230,6 +239,7 @@ func (h hollowLine) DrawLine(canvas draw.Image, p0 image.Point, p1 image.Point,
 
 	w := width / 20
 
+	setter := newPixelSetter(canvas, lineColor)
 	for ; x1 < x2; x1++ {
 		y := math.Sin(x1*math.Pi*multiple/float64(width)) * float64(height/3)
 
@@ -241,7 +251,7 @@ func (h hollowLine) DrawLine(canvas draw.Image, p0 image.Point, p1 image.Point,
 		y = math.Max(0, math.Min(float64(height-1), y))
 
 		for i := 0; i <= w && int(y)+i < height; i++ {
-			canvas.Set(int(x1), int(y)+i, lineColor)
+			setter.set(int(x1), int(y)+i)
 		}
 	}
 	return nil
@@ -1,13 +1,12 @@
 package gocaptcha
 
 import (
+	"context"
 	"image"
+	"image/color"
 	"image/draw"
 	"math/rand"
 	"time"
-
-	"github.com/golang/freetype"
-	"golang.org/x/image/font"
 )
 
 // NoiseDensity is the complexity of captcha
@@ -25,6 +24,20 @@ type NoiseDrawer interface {
 	DrawNoise(img draw.Image, density NoiseDensity) error
 }
 
+// ContextNoiseDrawer is implemented by noise drawers that can stop early when ctx is done.
+// CaptchaImage uses it instead of DrawNoise when available.
+type ContextNoiseDrawer interface {
+	DrawNoiseContext(ctx context.Context, img draw.Image, density NoiseDensity) error
+}
+
+// drawNoise calls DrawNoiseContext when drawer supports it, otherwise DrawNoise.
+func drawNoise(ctx context.Context, drawer NoiseDrawer, img draw.Image, density NoiseDensity) error {
+	if d, ok := drawer.(ContextNoiseDrawer); ok {
+		return d.DrawNoiseContext(ctx, img, density)
+	}
+	return drawer.DrawNoise(img, density)
+}
+
 type pointNoiseDrawer struct {
 	r *rand.Rand
 }
@@ -49,14 +62,18 @@ func (n pointNoiseDrawer) DrawNoise(img draw.Image, density NoiseDensity) error
 	width := bounds.Dx()
 	height := bounds.Dy()
 
+	// 每个噪声点只取两次随机数：一次决定位置和是否绘制额外的点，一次决定两个颜色.
+	// 逐个分量调用全局的 rand.Intn 会占据大部分时间，抵消直接写 Pix 带来的提升.
 	for i := 0; i < maxSize; i++ {
-		rw := n.r.Intn(width)
-		rh := n.r.Intn(height)
+		u := n.r.Uint64()
+		rw := int((u & 0xffffff) * uint64(width) >> 24)
+		rh := int((u >> 24 & 0xffffff) * uint64(height) >> 24)
+		colors := n.r.Uint64()
 
-		img.Set(rw, rh, RandColor())
+		setRGBA(img, rw, rh, randColorBits(uint32(colors)))
 		// 优化噪声点的生成逻辑，例如可以基于一定的概率决定是否绘制额外的点
-		if n.r.Intn(3) == 0 && rw+1 < width && rh+1 < height {
-			img.Set(rw+1, rh+1, RandColor())
+		if (u>>48)%3 == 0 && rw+1 < width && rh+1 < height {
+			setRGBA(img, rw+1, rh+1, randColorBits(uint32(colors>>32)))
 		}
 	}
 	return nil
@@ -77,6 +94,12 @@ type textNoiseDrawer struct {
 
 // DrawNoise draws noise on the image
 func (n textNoiseDrawer) DrawNoise(img draw.Image, density NoiseDensity) error {
+	return n.DrawNoiseContext(context.Background(), img, density)
+}
+
+// DrawNoiseContext draws noise on the image and stops with ctx.Err() before the next
+// glyph once ctx is done.
+func (n textNoiseDrawer) DrawNoiseContext(ctx context.Context, img draw.Image, density NoiseDensity) error {
 	var densityNum int
 	switch density {
 	case NoiseDensityLower:
@@ -90,36 +113,33 @@ func (n textNoiseDrawer) DrawNoise(img draw.Image, density NoiseDensity) error {
 	}
 	bounds := img.Bounds()
 	maxSize := (bounds.Dy() * bounds.Dx()) / densityNum
-	c := freetype.NewContext()
 	if n.dpi <= 0 {
 		n.dpi = 72
 	}
 
-	c.SetDPI(n.dpi)
-
-	c.SetClip(bounds)
-	c.SetDst(img)
-	c.SetHinting(font.HintingFull)
 	rawFontSize := float64(bounds.Dy()) / (1 + float64(n.r.Intn(7))/float64(10))
 
+	// 所有噪声字符共用一个颜色源，每个字符只更新 noiseColor
+	var noiseColor color.RGBA
+	src := image.NewUniform(&noiseColor)
 	for i := 0; i < maxSize; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		rw := n.r.Intn(bounds.Dx())
 		rh := n.r.Intn(bounds.Dy())
 
-		text := RandText(1)
+		text := TextCharacters[n.r.Intn(len(TextCharacters))]
 		fontSize := rawFontSize/2 + float64(n.r.Intn(5))
 
-		c.SetSrc(image.NewUniform(RandLightColor()))
-		c.SetFontSize(fontSize)
+		noiseColor = RandLightColor()
 		f, err := DefaultFontFamily.Random()
 		if err != nil {
 			return err
 		}
-		c.SetFont(f)
-		pt := freetype.Pt(rw, rh)
 
-		_, err = c.DrawString(text, pt)
+		_, err = DefaultGlyphCache.drawRune(img, f, fontSize, n.dpi, image.Pt(rw, rh), text, src)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,188 @@
+package gocaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// OddGlyphCharacters 镜像或旋转 180 度后与原字符明显不同的字符
+var OddGlyphCharacters = []rune("BCDEFGJKLPQRaefghkrty2345679")
+
+var ErrInvalidGlyphRecord = errors.New("invalid odd glyph record")
+
+// OddGlyphChallenge 找出与众不同字符的验证码题目.
+// 图片中有 Count 个相同的字符，其中下标为 Index 的字符经过了镜像或旋转.
+type OddGlyphChallenge struct {
+	Rune      rune
+	Count     int
+	Index     int
+	Transform GlyphTransform
+	// Boxes 每个字符在图片中的区域，绘制后填充
+	Boxes []image.Rectangle
+}
+
+// NewOddGlyphChallenge 随机生成一道包含 count 个字符的题目
+func NewOddGlyphChallenge(count int) *OddGlyphChallenge {
+	transform := GlyphMirror
+	if rand.Intn(2) == 0 {
+		transform = GlyphRotate180
+	}
+	return &OddGlyphChallenge{
+		Rune:      OddGlyphCharacters[rand.Intn(len(OddGlyphCharacters))],
+		Count:     count,
+		Index:     rand.Intn(count),
+		Transform: transform,
+	}
+}
+
+// Text 返回需要绘制的字符串
+func (c *OddGlyphChallenge) Text() string {
+	return strings.Repeat(string(c.Rune), c.Count)
+}
+
+// Transforms 返回每个字符对应的变换
+func (c *OddGlyphChallenge) Transforms() []GlyphTransform {
+	transforms := make([]GlyphTransform, c.Count)
+	transforms[c.Index] = c.Transform
+	return transforms
+}
+
+// HitTest 返回点 (x, y) 所在字符的下标，不在任何字符上时返回 -1
+func (c *OddGlyphChallenge) HitTest(x, y int) int {
+	return hitTest(c.Boxes, x, y)
+}
+
+// Match 判断点击位置是否落在与众不同的字符上
+func (c *OddGlyphChallenge) Match(x, y int) bool {
+	return c.HitTest(x, y) == c.Index
+}
+
+// record 将答案编码为可保存到 Store 的字符串，格式为 "下标|x0,y0,x1,y1;..."
+func (c *OddGlyphChallenge) record() string {
+	var sb strings.Builder
+	sb.WriteString(strconv.Itoa(c.Index))
+	sb.WriteByte('|')
+	for i, box := range c.Boxes {
+		if i > 0 {
+			sb.WriteByte(';')
+		}
+		_, _ = fmt.Fprintf(&sb, "%d,%d,%d,%d", box.Min.X, box.Min.Y, box.Max.X, box.Max.Y)
+	}
+	return sb.String()
+}
+
+// parseGlyphRecord 解析 record 生成的字符串
+func parseGlyphRecord(record string) (index int, boxes []image.Rectangle, err error) {
+	indexText, boxesText, ok := strings.Cut(record, "|")
+	if !ok {
+		return 0, nil, ErrInvalidGlyphRecord
+	}
+	if index, err = strconv.Atoi(indexText); err != nil {
+		return 0, nil, ErrInvalidGlyphRecord
+	}
+	for _, item := range strings.Split(boxesText, ";") {
+		var box image.Rectangle
+		if _, err = fmt.Sscanf(item, "%d,%d,%d,%d", &box.Min.X, &box.Min.Y, &box.Max.X, &box.Max.Y); err != nil {
+			return 0, nil, ErrInvalidGlyphRecord
+		}
+		boxes = append(boxes, box)
+	}
+	return index, boxes, nil
+}
+
+func hitTest(boxes []image.Rectangle, x, y int) int {
+	for i, box := range boxes {
+		if image.Pt(x, y).In(box) {
+			return i
+		}
+	}
+	return -1
+}
+
+// DrawOddGlyph 画找不同的字符，并记录每个字符的区域.
+func (captcha *CaptchaImage) DrawOddGlyph(drawer GlyphDrawer, challenge *OddGlyphChallenge) *CaptchaImage {
+	if captcha.failed() {
+		return captcha
+	}
+	challenge.Boxes, captcha.Error = drawer.DrawGlyphs(captcha.nrgba, challenge.Text(), challenge.Transforms())
+	return captcha
+}
+
+// GenerateOddGlyphCaptcha 生成找出镜像或旋转字符的验证码.
+// 用户需要点击与众不同的字符，可使用 OddGlyphChallenge.Match 校验点击位置.
+func GenerateOddGlyphCaptcha(width, height int, count int, difficulty CaptchaDifficulty) (challenge *OddGlyphChallenge, imgBytes []byte, err error) {
+	if count < 2 {
+		count = 2
+	}
+	challenge = NewOddGlyphChallenge(count)
+
+	bgColor := RandLightColor()
+	bgColor.A = 255
+	captchaImage := New(width, height, bgColor)
+	defer captchaImage.Release()
+
+	switch difficulty {
+	case CaptchaVeryEasy:
+		err = captchaImage.
+			DrawOddGlyph(NewGlyphDrawer(DefaultDPI, 0, 0), challenge).
+			Error
+	case CaptchaEasy:
+		err = captchaImage.
+			DrawOddGlyph(NewGlyphDrawer(DefaultDPI, DefaultAmplitude/8, DefaultFrequency), challenge).
+			DrawNoise(NoiseDensityLower/2, NewPointNoiseDrawer()).
+			Error
+	case CaptchaMedium:
+		err = captchaImage.
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawOddGlyph(NewGlyphDrawer(DefaultDPI, DefaultAmplitude/4, DefaultFrequency*2), challenge).
+			DrawLine(NewBeeline(), RandDeepColor()).
+			Error
+	default: // CaptchaHard
+		err = captchaImage.
+			DrawNoise(NoiseDensityLower, NewPointNoiseDrawer()).
+			DrawLine(NewBezier3DLine(), RandDeepColor()).
+			DrawOddGlyph(NewGlyphDrawer(DefaultDPI, DefaultAmplitude/3, DefaultFrequency*3), challenge).
+			DrawLine(NewBeeline(), RandDeepColor()).
+			DrawBlur(NewGaussianBlur(), DefaultBlurKernelSize, DefaultBlurSigma).
+			Error
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imgBytes, err = captchaImage.EncodeBytes(ImageFormatJpeg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return challenge, imgBytes, nil
+}
+
+// IssueOddGlyphCaptcha 生成找不同字符的验证码并将答案保存到 store，返回验证码 ID 和图片数据
+func IssueOddGlyphCaptcha(store Store, width, height int, count int, difficulty CaptchaDifficulty) (id string, imgBytes []byte, err error) {
+	challenge, imgBytes, err := GenerateOddGlyphCaptcha(width, height, count, difficulty)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err = Issue(store, challenge.record())
+	if err != nil {
+		return "", nil, err
+	}
+	return id, imgBytes, nil
+}
+
+// VerifyOddGlyph 校验用户点击的位置 (x, y) 是否为与众不同的字符，验证码只能校验一次
+func VerifyOddGlyph(store Store, id string, x, y int) bool {
+	record, err := getAnswer(store, id)
+	if err != nil {
+		return false
+	}
+	index, boxes, err := parseGlyphRecord(record)
+	if err != nil {
+		return false
+	}
+	return hitTest(boxes, x, y) == index
+}
@@ -0,0 +1,89 @@
+package gocaptcha
+
+import (
+	"image"
+	"testing"
+)
+
+func TestOddGlyphChallenge_record(t *testing.T) {
+	c := &OddGlyphChallenge{
+		Rune:  'K',
+		Count: 3,
+		Index: 2,
+		Boxes: []image.Rectangle{image.Rect(0, 0, 10, 20), image.Rect(10, 0, 20, 20), image.Rect(20, 0, 30, 20)},
+	}
+	index, boxes, err := parseGlyphRecord(c.record())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != c.Index {
+		t.Errorf("parseGlyphRecord() index = %v, want %v", index, c.Index)
+	}
+	if len(boxes) != len(c.Boxes) {
+		t.Fatalf("parseGlyphRecord() len(boxes) = %v, want %v", len(boxes), len(c.Boxes))
+	}
+	for i := range boxes {
+		if boxes[i] != c.Boxes[i] {
+			t.Errorf("parseGlyphRecord() boxes[%d] = %v, want %v", i, boxes[i], c.Boxes[i])
+		}
+	}
+
+	for _, record := range []string{"", "x|0,0,1,1", "1|a,b,c,d"} {
+		if _, _, err := parseGlyphRecord(record); err == nil {
+			t.Errorf("parseGlyphRecord(%q) should return error", record)
+		}
+	}
+}
+
+func TestOddGlyphChallenge_HitTest(t *testing.T) {
+	c := &OddGlyphChallenge{
+		Count: 2,
+		Index: 1,
+		Boxes: []image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10)},
+	}
+	tests := []struct {
+		name      string
+		x, y      int
+		wantIndex int
+		wantMatch bool
+	}{
+		{name: "first glyph", x: 5, y: 5, wantIndex: 0, wantMatch: false},
+		{name: "odd glyph", x: 15, y: 5, wantIndex: 1, wantMatch: true},
+		{name: "outside", x: 25, y: 5, wantIndex: -1, wantMatch: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.HitTest(tt.x, tt.y); got != tt.wantIndex {
+				t.Errorf("HitTest() = %v, want %v", got, tt.wantIndex)
+			}
+			if got := c.Match(tt.x, tt.y); got != tt.wantMatch {
+				t.Errorf("Match() = %v, want %v", got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestIssueOddGlyphCaptcha(t *testing.T) {
+	store := NewMemoryStore(DefaultExpiration)
+	for _, difficulty := range []CaptchaDifficulty{CaptchaVeryEasy, CaptchaEasy, CaptchaMedium, CaptchaHard} {
+		id, imgBytes, err := IssueOddGlyphCaptcha(store, 300, 70, 5, difficulty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(imgBytes) == 0 {
+			t.Errorf("IssueOddGlyphCaptcha() returned empty image")
+		}
+		record, _ := store.Get(id, false)
+		index, boxes, err := parseGlyphRecord(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		center := boxes[index].Min.Add(boxes[index].Max).Div(2)
+		if !VerifyOddGlyph(store, id, center.X, center.Y) {
+			t.Errorf("VerifyOddGlyph() = false, want true")
+		}
+		if VerifyOddGlyph(store, id, center.X, center.Y) {
+			t.Errorf("VerifyOddGlyph() succeeded twice")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package gocaptcha
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// minParallelRows 每个分段至少包含的行数，过小的分段调度开销大于收益
+const minParallelRows = 16
+
+// parallelism 整图处理使用的 goroutine 数量上限，0 和 1 表示串行
+var parallelism atomic.Int32
+
+// SetParallelism 设置模糊、扭曲等整图处理使用的 goroutine 数量上限.
+// 图片按行分成若干段并行处理，结果与串行完全相同. n 小于等于 1 时串行处理，这也是默认值.
+// 服务端并发生成验证码时请求之间已经并行，通常只在批量生成大图时需要设置.
+func SetParallelism(n int) {
+	parallelism.Store(int32(max(n, 1)))
+}
+
+// Parallelism 返回 SetParallelism 设置的值
+func Parallelism() int {
+	return max(int(parallelism.Load()), 1)
+}
+
+// parallelBands 将 [0, n) 分成连续的段，每段至少 minBand 个，并行调用 fn 处理.
+// 每段只能写入属于自己的部分，这样分段方式不影响结果.
+// 返回序号最小的分段的错误，与串行处理时遇到的第一个错误一致.
+func parallelBands(n int, minBand int, fn func(lo, hi int) error) error {
+	bands := min(Parallelism(), n/max(minBand, 1))
+	if bands <= 1 {
+		return fn(0, n)
+	}
+
+	errs := make([]error, bands)
+	var wg sync.WaitGroup
+	wg.Add(bands - 1)
+	for i := 1; i < bands; i++ {
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(n*i/bands, n*(i+1)/bands)
+		}(i)
+	}
+	errs[0] = fn(0, n/bands)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
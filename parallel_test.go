@@ -0,0 +1,152 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"sync/atomic"
+	"testing"
+)
+
+// withParallelism runs fn with SetParallelism(n).
+func withParallelism(n int, fn func()) {
+	old := Parallelism()
+	SetParallelism(n)
+	defer SetParallelism(old)
+	fn()
+}
+
+func TestParallelBands(t *testing.T) {
+	withParallelism(4, func() {
+		for _, n := range []int{0, 1, 15, 33, 64, 1000} {
+			counts := make([]atomic.Int32, n)
+			err := parallelBands(n, minParallelRows, func(lo, hi int) error {
+				for i := lo; i < hi; i++ {
+					counts[i].Add(1)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range counts {
+				if c := counts[i].Load(); c != 1 {
+					t.Fatalf("n = %d: index %d visited %d times", n, i, c)
+				}
+			}
+		}
+
+		// 多个分段出错时返回第一个分段的错误
+		errFirst, errLast := errors.New("first"), errors.New("last")
+		err := parallelBands(100, 1, func(lo, hi int) error {
+			if lo == 0 {
+				return errFirst
+			}
+			if hi == 100 {
+				return errLast
+			}
+			return nil
+		})
+		if err != errFirst {
+			t.Errorf("parallelBands() error = %v, want %v", err, errFirst)
+		}
+	})
+
+	SetParallelism(-1)
+	if got := Parallelism(); got != 1 {
+		t.Errorf("Parallelism() = %d after SetParallelism(-1), want 1", got)
+	}
+}
+
+// TestParallelMatchesSerial checks that every full-image pass gives the same pixels
+// whatever the number of bands.
+func TestParallelMatchesSerial(t *testing.T) {
+	passes := map[string]func(draw.Image) error{
+		"gaussian": func(m draw.Image) error { return NewGaussianBlur().DrawBlur(m, 5, 1.5) },
+		"box":      func(m draw.Image) error { return NewBoxGaussianBlur(3).DrawBlur(m, 0, 2) },
+		"boxblur":  NewBoxBlur(2).DrawFilter,
+		"median":   NewMedianFilter(1).DrawFilter,
+		"motion":   NewMotionBlur(30, 5).DrawFilter,
+		"sharpen":  NewSharpen(1, 1).DrawFilter,
+		"twist": func(m draw.Image) error {
+			return twistEffect(randomNRGBA(m.Bounds().Dx(), m.Bounds().Dy(), 3), m, DefaultAmplitude, DefaultFrequency)
+		},
+		"twist-mixed": func(m draw.Image) error {
+			return twistEffect(randomRGBA(m.Bounds().Dx(), m.Bounds().Dy(), 3), m, DefaultAmplitude, DefaultFrequency)
+		},
+	}
+	for name, apply := range passes {
+		// 行数不能被分段数整除
+		for _, size := range []image.Point{{X: 180, Y: 60}, {X: 203, Y: 151}} {
+			t.Run(fmt.Sprintf("%s/%dx%d", name, size.X, size.Y), func(t *testing.T) {
+				want := randomNRGBA(size.X, size.Y, 1)
+				got := randomNRGBA(size.X, size.Y, 1)
+				withParallelism(1, func() {
+					if err := apply(want); err != nil {
+						t.Fatal(err)
+					}
+				})
+				for _, n := range []int{2, 3, 8} {
+					copy(got.Pix, randomNRGBA(size.X, size.Y, 1).Pix)
+					withParallelism(n, func() {
+						if err := apply(got); err != nil {
+							t.Fatal(err)
+						}
+					})
+					if !bytes.Equal(got.Pix, want.Pix) {
+						t.Fatalf("parallelism %d differs from serial", n)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParallelFilterContextCancelled(t *testing.T) {
+	withParallelism(4, func() {
+		canvas := randomNRGBA(120, 100, 1)
+		before := bytes.Clone(canvas.Pix)
+		ctx := newCountdownContext(20)
+		err := NewMedianFilter(1).(ContextFilterDrawer).DrawFilterContext(ctx, canvas)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("DrawFilterContext() error = %v, want %v", err, context.Canceled)
+		}
+		if !bytes.Equal(canvas.Pix, before) {
+			t.Error("cancelled filter changed the canvas")
+		}
+	})
+}
+
+func BenchmarkParallelism(b *testing.B) {
+	twistSrc := randomNRGBA(1600, 600, 3)
+	passes := []struct {
+		name  string
+		apply func(*image.NRGBA) error
+	}{
+		{"gaussian", func(m *image.NRGBA) error { return NewGaussianBlur().DrawBlur(m, 5, 1.5) }},
+		{"box", func(m *image.NRGBA) error { return NewBoxGaussianBlur(3).DrawBlur(m, 0, 2) }},
+		{"median", func(m *image.NRGBA) error { return NewMedianFilter(1).DrawFilter(m) }},
+		{"twist", func(m *image.NRGBA) error {
+			return twistEffect(twistSrc.SubImage(m.Rect), m, DefaultAmplitude, DefaultFrequency)
+		}},
+	}
+	for _, size := range []image.Point{{X: 400, Y: 150}, {X: 1600, Y: 600}} {
+		for _, pass := range passes {
+			for _, n := range []int{1, 4} {
+				b.Run(fmt.Sprintf("%s/%dx%d/parallelism=%d", pass.name, size.X, size.Y, n), func(b *testing.B) {
+					m := randomNRGBA(size.X, size.Y, 1)
+					withParallelism(n, func() {
+						for i := 0; i < b.N; i++ {
+							if err := pass.apply(m); err != nil {
+								b.Fatal(err)
+							}
+						}
+					})
+				})
+			}
+		}
+	}
+}
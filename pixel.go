@@ -0,0 +1,174 @@
+package gocaptcha
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// pixelSetter writes a single color into an image. For *image.NRGBA and *image.RGBA
+// the color is converted once and written directly into the Pix slice, other images
+// fall back to draw.Image.Set.
+type pixelSetter struct {
+	img    draw.Image
+	c      color.Color
+	pix    []uint8
+	stride int
+	rect   image.Rectangle
+	v      [4]uint8
+}
+
+// newPixelSetter returns a setter that writes c into img.
+func newPixelSetter(img draw.Image, c color.Color) pixelSetter {
+	s := pixelSetter{img: img, c: c}
+	switch m := img.(type) {
+	case *image.NRGBA:
+		v := color.NRGBAModel.Convert(c).(color.NRGBA)
+		s.pix, s.stride, s.rect, s.v = m.Pix, m.Stride, m.Rect, [4]uint8{v.R, v.G, v.B, v.A}
+	case *image.RGBA:
+		v := color.RGBAModel.Convert(c).(color.RGBA)
+		s.pix, s.stride, s.rect, s.v = m.Pix, m.Stride, m.Rect, [4]uint8{v.R, v.G, v.B, v.A}
+	}
+	return s
+}
+
+// newNRGBAPixelSetter is newPixelSetter for a color.NRGBA. It does not box c for
+// *image.NRGBA and *image.RGBA, which matters when every point gets a different color.
+func newNRGBAPixelSetter(img draw.Image, c color.NRGBA) pixelSetter {
+	s := pixelSetter{img: img}
+	switch m := img.(type) {
+	case *image.NRGBA:
+		s.pix, s.stride, s.rect = m.Pix, m.Stride, m.Rect
+	case *image.RGBA:
+		s.pix, s.stride, s.rect = m.Pix, m.Stride, m.Rect
+	}
+	s.setNRGBA(c)
+	return s
+}
+
+// setNRGBA changes the color written by s, so one setter can be reused while the
+// color changes from point to point.
+func (s *pixelSetter) setNRGBA(c color.NRGBA) {
+	switch s.img.(type) {
+	case *image.NRGBA:
+		s.v = [4]uint8{c.R, c.G, c.B, c.A}
+	case *image.RGBA:
+		r, g, b, a := c.RGBA()
+		s.v = [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+	default:
+		s.c = c
+	}
+}
+
+// set writes the color at (x, y), points outside the image are ignored.
+func (s pixelSetter) set(x, y int) {
+	if s.pix == nil {
+		s.img.Set(x, y, s.c)
+		return
+	}
+	if x < s.rect.Min.X || x >= s.rect.Max.X || y < s.rect.Min.Y || y >= s.rect.Max.Y {
+		return
+	}
+	i := (y-s.rect.Min.Y)*s.stride + (x-s.rect.Min.X)*4
+	d := s.pix[i : i+4 : i+4]
+	d[0], d[1], d[2], d[3] = s.v[0], s.v[1], s.v[2], s.v[3]
+}
+
+// fillSpan writes the color to the pixels x0..x1 of row y. The span is clipped to
+// the image once and then written straight into Pix.
+func (s pixelSetter) fillSpan(x0, x1, y int) {
+	if s.pix == nil {
+		for x := x0; x <= x1; x++ {
+			s.img.Set(x, y, s.c)
+		}
+		return
+	}
+	if y < s.rect.Min.Y || y >= s.rect.Max.Y {
+		return
+	}
+	x0, x1 = max(x0, s.rect.Min.X), min(x1, s.rect.Max.X-1)
+	if x0 > x1 {
+		return
+	}
+	i := (y-s.rect.Min.Y)*s.stride + (x0-s.rect.Min.X)*4
+	row := s.pix[i : i+(x1-x0+1)*4]
+	for j := 0; j < len(row); j += 4 {
+		d := row[j : j+4 : j+4]
+		d[0], d[1], d[2], d[3] = s.v[0], s.v[1], s.v[2], s.v[3]
+	}
+}
+
+// setRGBA writes c to img at (x, y) without boxing the color for *image.NRGBA and
+// *image.RGBA, which matters when every pixel gets a different color.
+func setRGBA(img draw.Image, x, y int, c color.RGBA) {
+	r, g, b, a := c.RGBA()
+	if !setPremultiplied(img, x, y, r, g, b, a) {
+		img.Set(x, y, c)
+	}
+}
+
+// isPixImage reports whether img is an *image.NRGBA or *image.RGBA that can be
+// accessed through its Pix slice.
+func isPixImage(img image.Image) bool {
+	switch img.(type) {
+	case *image.NRGBA, *image.RGBA:
+		return true
+	}
+	return false
+}
+
+// premultipliedAt returns the 16-bit premultiplied color of src at (x, y), the same
+// values as src.At(x, y).RGBA(), reading *image.NRGBA and *image.RGBA directly.
+func premultipliedAt(src image.Image, x, y int) (r, g, b, a uint32) {
+	switch m := src.(type) {
+	case *image.NRGBA:
+		if !(image.Point{X: x, Y: y}.In(m.Rect)) {
+			return 0, 0, 0, 0
+		}
+		i := m.PixOffset(x, y)
+		s := m.Pix[i : i+4 : i+4]
+		a = uint32(s[3]) * 0x101
+		r = uint32(s[0]) * 0x101 * a / 0xffff
+		g = uint32(s[1]) * 0x101 * a / 0xffff
+		b = uint32(s[2]) * 0x101 * a / 0xffff
+		return r, g, b, a
+	case *image.RGBA:
+		if !(image.Point{X: x, Y: y}.In(m.Rect)) {
+			return 0, 0, 0, 0
+		}
+		i := m.PixOffset(x, y)
+		s := m.Pix[i : i+4 : i+4]
+		return uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101
+	}
+	return src.At(x, y).RGBA()
+}
+
+// setPremultiplied writes a 16-bit premultiplied color to dst at (x, y), converting
+// it the same way as dst.Set would. It reports false when dst has no fast path.
+func setPremultiplied(dst draw.Image, x, y int, r, g, b, a uint32) bool {
+	switch m := dst.(type) {
+	case *image.NRGBA:
+		if !(image.Point{X: x, Y: y}.In(m.Rect)) {
+			return true
+		}
+		// same as color.NRGBAModel
+		if a != 0xffff && a != 0 {
+			r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+		} else if a == 0 {
+			r, g, b = 0, 0, 0
+		}
+		i := m.PixOffset(x, y)
+		d := m.Pix[i : i+4 : i+4]
+		d[0], d[1], d[2], d[3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+		return true
+	case *image.RGBA:
+		if !(image.Point{X: x, Y: y}.In(m.Rect)) {
+			return true
+		}
+		i := m.PixOffset(x, y)
+		d := m.Pix[i : i+4 : i+4]
+		d[0], d[1], d[2], d[3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+		return true
+	}
+	return false
+}
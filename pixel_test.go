@@ -0,0 +1,281 @@
+package gocaptcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// genericImage hides the concrete image type so drawers take the draw.Image path.
+type genericImage struct {
+	draw.Image
+}
+
+var pixelBenchSizes = []image.Point{{X: 180, Y: 60}, {X: 400, Y: 150}}
+
+// randomRGBA returns an image with random premultiplied pixels, every other pixel opaque.
+func randomRGBA(w, h int, seed int64) *image.RGBA {
+	src := randomNRGBA(w, h, seed)
+	m := image.NewRGBA(src.Bounds())
+	draw.Draw(m, m.Bounds(), src, image.Point{}, draw.Src)
+	return m
+}
+
+// referenceTwist is the previous twistEffect implementation using At and Set only.
+func referenceTwist(src image.Image, dst draw.Image, amplitude float64, frequency float64) {
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dx := int(amplitude * math.Sin(frequency*float64(y)))
+			newX := x + dx
+			if newX >= 0 && newX < width {
+				_, _, _, a := src.At(x, y).RGBA()
+				if a != 0 {
+					dst.Set(newX, y, src.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestPixelSetter(t *testing.T) {
+	colors := []color.Color{
+		color.RGBA{R: 10, G: 200, B: 30, A: 255},
+		color.RGBA{R: 10, G: 100, B: 30, A: 128},
+		color.NRGBA{R: 250, G: 128, B: 3, A: 77},
+		color.RGBA64{R: 0x1234, G: 0x5678, B: 0x9abc, A: 0xdef0},
+		color.Gray{Y: 99},
+		color.Transparent,
+	}
+	rect := image.Rect(-3, 2, 17, 11)
+	for _, c := range colors {
+		for _, newImage := range []func() draw.Image{
+			func() draw.Image { return image.NewNRGBA(rect) },
+			func() draw.Image { return image.NewRGBA(rect) },
+		} {
+			got, want := newImage(), newImage()
+			setter := newPixelSetter(got, c)
+			for y := rect.Min.Y - 2; y < rect.Max.Y+2; y++ {
+				for x := rect.Min.X - 2; x < rect.Max.X+2; x++ {
+					if (x+y)%3 == 0 {
+						setter.set(x, y)
+						want.Set(x, y, c)
+					}
+				}
+			}
+			if d, _ := maxPixelDiff(got, want); d != 0 {
+				t.Errorf("pixelSetter %T %v differs from Set by %d", got, c, d)
+			}
+		}
+	}
+}
+
+func TestPixelSetterFillSpan(t *testing.T) {
+	c := color.NRGBA{R: 250, G: 128, B: 3, A: 77}
+	rect := image.Rect(-3, 2, 17, 11)
+	for _, newImage := range []func() draw.Image{
+		func() draw.Image { return image.NewNRGBA(rect) },
+		func() draw.Image { return image.NewRGBA(rect) },
+	} {
+		got, want := newImage(), newImage()
+		setter := newNRGBAPixelSetter(got, color.NRGBA{})
+		setter.setNRGBA(c)
+		// 跨越左右边界、完全在图片外以及空的区间
+		for _, span := range [][3]int{{-10, 4, 3}, {5, 30, 4}, {-10, 30, 5}, {20, 25, 6}, {0, 3, 1}, {0, 3, 11}, {4, 2, 7}} {
+			setter.fillSpan(span[0], span[1], span[2])
+			for x := span[0]; x <= span[1]; x++ {
+				want.Set(x, span[2], c)
+			}
+		}
+		if d, _ := maxPixelDiff(got, want); d != 0 {
+			t.Errorf("fillSpan %T differs from Set by %d", got, d)
+		}
+	}
+}
+
+func TestPremultipliedAt(t *testing.T) {
+	nrgba := randomNRGBA(16, 8, 1)
+	sub := nrgba.SubImage(image.Rect(3, 2, 12, 7))
+	for _, src := range []image.Image{nrgba, randomRGBA(16, 8, 2), sub, genericImage{nrgba}} {
+		bounds := src.Bounds()
+		for y := bounds.Min.Y - 1; y <= bounds.Max.Y; y++ {
+			for x := bounds.Min.X - 1; x <= bounds.Max.X; x++ {
+				r, g, b, a := premultipliedAt(src, x, y)
+				wr, wg, wb, wa := src.At(x, y).RGBA()
+				if r != wr || g != wg || b != wb || a != wa {
+					t.Fatalf("premultipliedAt(%T, %d, %d) = %d %d %d %d, want %d %d %d %d", src, x, y, r, g, b, a, wr, wg, wb, wa)
+				}
+			}
+		}
+	}
+}
+
+func TestTwistEffectFastPath(t *testing.T) {
+	const w, h = 180, 60
+	sources := []image.Image{randomNRGBA(w, h, 1), randomRGBA(w, h, 2)}
+	targets := []func() draw.Image{
+		func() draw.Image { return randomNRGBA(w, h, 3) },
+		func() draw.Image { return randomRGBA(w, h, 4) },
+	}
+	for _, src := range sources {
+		for _, newTarget := range targets {
+			got, want := newTarget(), newTarget()
+			if err := twistEffect(src, got, DefaultAmplitude, DefaultFrequency); err != nil {
+				t.Fatal(err)
+			}
+			referenceTwist(genericImage{src.(draw.Image)}, genericImage{want}, DefaultAmplitude, DefaultFrequency)
+			if d, _ := maxPixelDiff(got, want); d != 0 {
+				t.Errorf("twistEffect %T -> %T differs from reference by %d", src, got, d)
+			}
+
+			// 通用接口路径
+			generic := newTarget()
+			if err := twistEffect(genericImage{src.(draw.Image)}, genericImage{generic}, DefaultAmplitude, DefaultFrequency); err != nil {
+				t.Fatal(err)
+			}
+			if d, _ := maxPixelDiff(generic, want); d != 0 {
+				t.Errorf("generic twistEffect %T -> %T differs from reference by %d", src, got, d)
+			}
+		}
+	}
+}
+
+func TestLineDrawersFastPath(t *testing.T) {
+	const w, h = 180, 60
+	drawers := map[string]func(seed int64) LineDrawer{
+		"beeline":      func(int64) LineDrawer { return NewBeeline() },
+		"bezier":       func(seed int64) LineDrawer { return &bezierLine{r: rand.New(rand.NewSource(seed))} },
+		"bezier3D":     func(seed int64) LineDrawer { return &bezier3DLine{r: rand.New(rand.NewSource(seed))} },
+		"hollow":       func(seed int64) LineDrawer { return &hollowLine{r: rand.New(rand.NewSource(seed))} },
+		"bezier3DRGBA": func(seed int64) LineDrawer { return &bezier3DLine{r: rand.New(rand.NewSource(seed))} },
+	}
+	for name, newDrawer := range drawers {
+		t.Run(name, func(t *testing.T) {
+			newCanvas := func() draw.Image { return randomNRGBA(w, h, 1) }
+			if name == "bezier3DRGBA" {
+				newCanvas = func() draw.Image { return randomRGBA(w, h, 1) }
+			}
+			got, want := newCanvas(), newCanvas()
+			p0, p1 := image.Pt(-5, 10), image.Pt(w*2/3, h-3)
+			lineColor := color.NRGBA{R: 200, G: 40, B: 90, A: 180}
+			if err := newDrawer(7).DrawLine(got, p0, p1, lineColor); err != nil {
+				t.Fatal(err)
+			}
+			if err := newDrawer(7).DrawLine(genericImage{want}, p0, p1, lineColor); err != nil {
+				t.Fatal(err)
+			}
+			if d, _ := maxPixelDiff(got, want); d != 0 {
+				t.Errorf("DrawLine fast path differs from generic path by %d", d)
+			}
+		})
+	}
+}
+
+func TestDrawBorderFastPath(t *testing.T) {
+	borderColor := color.RGBA{R: 20, G: 40, B: 60, A: 200}
+	captcha := New(40, 20, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	captcha.DrawBorder(borderColor)
+
+	want := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(want, want.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	for x := 0; x < 40; x++ {
+		want.Set(x, 0, borderColor)
+		want.Set(x, 19, borderColor)
+	}
+	for y := 0; y < 20; y++ {
+		want.Set(0, y, borderColor)
+		want.Set(39, y, borderColor)
+	}
+	if !bytes.Equal(captcha.nrgba.Pix, want.Pix) {
+		t.Error("DrawBorder() output differs from Set")
+	}
+}
+
+// benchmarkPixelPaths runs fn on a fast *image.NRGBA canvas and on the same canvas
+// behind the draw.Image interface.
+func benchmarkPixelPaths(b *testing.B, fn func(canvas draw.Image)) {
+	for _, size := range pixelBenchSizes {
+		canvas := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		b.Run(fmt.Sprintf("fast/%dx%d", size.X, size.Y), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fn(canvas)
+			}
+		})
+		b.Run(fmt.Sprintf("generic/%dx%d", size.X, size.Y), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fn(genericImage{canvas})
+			}
+		})
+	}
+}
+
+func BenchmarkDrawBorder(b *testing.B) {
+	borderColor := color.RGBA{R: 20, G: 40, B: 60, A: 255}
+	benchmarkPixelPaths(b, func(canvas draw.Image) {
+		bounds := canvas.Bounds()
+		setter := newPixelSetter(canvas, borderColor)
+		for x := 0; x < bounds.Dx(); x++ {
+			setter.set(x, 0)
+			setter.set(x, bounds.Dy()-1)
+		}
+		for y := 0; y < bounds.Dy(); y++ {
+			setter.set(0, y)
+			setter.set(bounds.Dx()-1, y)
+		}
+	})
+}
+
+func BenchmarkPointNoise(b *testing.B) {
+	drawer := &pointNoiseDrawer{r: rand.New(rand.NewSource(1))}
+	benchmarkPixelPaths(b, func(canvas draw.Image) {
+		_ = drawer.DrawNoise(canvas, NoiseDensityHigh)
+	})
+}
+
+func BenchmarkTwistEffect(b *testing.B) {
+	for _, size := range pixelBenchSizes {
+		src := randomRGBA(size.X, size.Y, 1)
+		dst := image.NewNRGBA(src.Bounds())
+		b.Run(fmt.Sprintf("reference/%dx%d", size.X, size.Y), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				referenceTwist(src, dst, DefaultAmplitude, DefaultFrequency)
+			}
+		})
+		b.Run(fmt.Sprintf("fast/%dx%d", size.X, size.Y), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = twistEffect(src, dst, DefaultAmplitude, DefaultFrequency)
+			}
+		})
+		// glyph 图层与扭曲结果类型相同时直接复制
+		rgbaDst := image.NewRGBA(src.Bounds())
+		b.Run(fmt.Sprintf("fast-same-type/%dx%d", size.X, size.Y), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = twistEffect(src, rgbaDst, DefaultAmplitude, DefaultFrequency)
+			}
+		})
+	}
+}
+
+func BenchmarkLineDrawers(b *testing.B) {
+	drawers := map[string]LineDrawer{
+		"beeline":  NewBeeline(),
+		"bezier":   &bezierLine{r: rand.New(rand.NewSource(1))},
+		"bezier3D": &bezier3DLine{r: rand.New(rand.NewSource(1))},
+		"hollow":   &hollowLine{r: rand.New(rand.NewSource(1))},
+	}
+	lineColor := color.RGBA{R: 200, G: 40, B: 90, A: 255}
+	for name, drawer := range drawers {
+		b.Run(name, func(b *testing.B) {
+			benchmarkPixelPaths(b, func(canvas draw.Image) {
+				bounds := canvas.Bounds()
+				_ = drawer.DrawLine(canvas, image.Pt(0, bounds.Dy()/3), image.Pt(bounds.Dx()-1, bounds.Dy()*2/3), lineColor)
+			})
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package gocaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultPoolSize 每个预设默认保留的题目数量
+	DefaultPoolSize = 32
+	// DefaultPoolRetryDelay 生成失败后 worker 重试前等待的时间
+	DefaultPoolRetryDelay = time.Second
+)
+
+var (
+	ErrPoolClosed        = errors.New("captcha pool closed")
+	ErrUnknownPoolPreset = errors.New("unknown captcha pool preset")
+	ErrDuplicatePreset   = errors.New("duplicate captcha pool preset")
+)
+
+// PoolPreset 预生成的一类题目
+type PoolPreset struct {
+	// Name 预设名称，Pool.Get 使用该名称取题目，为空时使用 Type
+	Name string
+	// Type 题目类型，为空时使用 ChallengeText
+	Type string
+	// Options 生成题目的参数，预生成的题目使用固定的参数，包括 Locale
+	Options ChallengeOptions
+	// Size 保留的题目数量，为 0 时使用 DefaultPoolSize
+	Size int
+}
+
+// PoolPresetName 返回 DifficultyPresets 中预设的名称，例如 "text/3"
+func PoolPresetName(typeName string, difficulty CaptchaDifficulty) string {
+	return fmt.Sprintf("%s/%d", typeName, difficulty)
+}
+
+// DifficultyPresets 为 typeName 类型的每个难度级别各返回一个预设，名称见 PoolPresetName
+func DifficultyPresets(typeName string, opts ChallengeOptions, size int) []PoolPreset {
+	presets := make([]PoolPreset, 0, int(CaptchaHard)+1)
+	for d := CaptchaVeryEasy; d <= CaptchaHard; d++ {
+		o := opts
+		o.Difficulty = d
+		presets = append(presets, PoolPreset{Name: PoolPresetName(typeName, d), Type: typeName, Options: o, Size: size})
+	}
+	return presets
+}
+
+// PoolStats 题目池的统计信息
+type PoolStats struct {
+	// Hits 直接从池中取到题目的次数
+	Hits uint64
+	// Misses 池为空时同步生成的次数
+	Misses uint64
+	// Generated 后台生成的题目数量
+	Generated uint64
+	// Errors 后台生成失败的次数
+	Errors uint64
+	// Ready 池中可用的题目总数
+	Ready int
+	// Capacity 所有预设的容量之和
+	Capacity int
+	// Depth 每个预设当前可用的题目数量
+	Depth map[string]int
+}
+
+// HitRate 返回命中率，没有请求时返回 0
+func (s PoolStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type poolQueue struct {
+	preset PoolPreset
+	ready  chan *Challenge
+	// pending 正在后台生成的数量，避免多个 worker 同时补充同一个预设超出容量
+	pending int
+}
+
+// Pool 预生成题目的池，供高并发场景使用.
+// 后台由固定数量的 worker 按预设补充题目，Get 在池为空时同步生成.
+// 池中的题目尚未保存，需要通过 Verifier.IssuePooled 保存校验记录并分配 ID.
+type Pool struct {
+	registry *ChallengeRegistry
+	queues   map[string]*poolQueue
+	names    []string
+
+	mu sync.Mutex
+	// ctx 在 Close 时取消，正在进行的后台绘制随之停止
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	generated atomic.Uint64
+	errors    atomic.Uint64
+}
+
+// NewPool 创建题目池并启动 workers 个后台 worker，workers 为 0 时使用 runtime.NumCPU().
+// registry 为空时使用 DefaultChallengeRegistry，使用完毕后需要调用 Close.
+func NewPool(registry *ChallengeRegistry, workers int, presets ...PoolPreset) (*Pool, error) {
+	if registry == nil {
+		registry = DefaultChallengeRegistry
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	p := &Pool{
+		registry: registry,
+		queues:   make(map[string]*poolQueue, len(presets)),
+		wake:     make(chan struct{}, workers),
+	}
+	for _, preset := range presets {
+		if preset.Type == "" {
+			preset.Type = ChallengeText
+		}
+		if preset.Name == "" {
+			preset.Name = preset.Type
+		}
+		if preset.Size <= 0 {
+			preset.Size = DefaultPoolSize
+		}
+		if _, ok := registry.Lookup(preset.Type); !ok {
+			return nil, ErrUnknownChallengeType
+		}
+		if _, ok := p.queues[preset.Name]; ok {
+			return nil, ErrDuplicatePreset
+		}
+		p.queues[preset.Name] = &poolQueue{preset: preset, ready: make(chan *Challenge, preset.Size)}
+		p.names = append(p.names, preset.Name)
+	}
+	sort.Strings(p.names)
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p, nil
+}
+
+// Has 判断是否存在名称为 name 的预设
+func (p *Pool) Has(name string) bool {
+	_, ok := p.queues[name]
+	return ok
+}
+
+// Get 取出预设 name 的一个题目，池为空时同步生成.
+// ctx 已取消时返回 ctx.Err()，池关闭后返回 ErrPoolClosed.
+func (p *Pool) Get(ctx context.Context, name string) (*Challenge, error) {
+	q, ok := p.queues[name]
+	if !ok {
+		return nil, ErrUnknownPoolPreset
+	}
+	if p.ctx.Err() != nil {
+		return nil, ErrPoolClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case c := <-q.ready:
+		p.hits.Add(1)
+		p.notify()
+		return c, nil
+	default:
+	}
+	p.misses.Add(1)
+	p.notify()
+	return NewChallengeContext(ctx, p.registry, q.preset.Type, q.preset.Options)
+}
+
+// Close 停止后台 worker 并等待其退出，丢弃池中剩余的题目
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+		for _, q := range p.queues {
+			for len(q.ready) > 0 {
+				<-q.ready
+			}
+		}
+	})
+	return nil
+}
+
+// Stats 返回当前的统计信息
+func (p *Pool) Stats() PoolStats {
+	s := PoolStats{
+		Hits:      p.hits.Load(),
+		Misses:    p.misses.Load(),
+		Generated: p.generated.Load(),
+		Errors:    p.errors.Load(),
+		Depth:     make(map[string]int, len(p.queues)),
+	}
+	for name, q := range p.queues {
+		n := len(q.ready)
+		s.Depth[name] = n
+		s.Ready += n
+		s.Capacity += cap(q.ready)
+	}
+	return s
+}
+
+// notify 唤醒一个空闲的 worker
+func (p *Pool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next 返回最需要补充的预设并将其 pending 加一，全部已满时返回 nil
+func (p *Pool) next() *poolQueue {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *poolQueue
+	var bestFill float64
+	for _, name := range p.names {
+		q := p.queues[name]
+		n := len(q.ready) + q.pending
+		if n >= cap(q.ready) {
+			continue
+		}
+		fill := float64(n) / float64(cap(q.ready))
+		if best == nil || fill < bestFill {
+			best, bestFill = q, fill
+		}
+	}
+	if best != nil {
+		best.pending++
+	}
+	return best
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for {
+		q := p.next()
+		if q == nil {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-p.wake:
+			}
+			continue
+		}
+
+		c, err := NewChallengeContext(p.ctx, p.registry, q.preset.Type, q.preset.Options)
+		p.mu.Lock()
+		q.pending--
+		p.mu.Unlock()
+		if p.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			p.errors.Add(1)
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(DefaultPoolRetryDelay):
+			}
+			continue
+		}
+		p.generated.Add(1)
+		select {
+		case q.ready <- c:
+		default:
+		}
+	}
+}
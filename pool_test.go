@@ -0,0 +1,233 @@
+package gocaptcha
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingChallenge 生成 "1"、"2"... 作为答案，用于观察池的行为
+type countingChallenge struct {
+	n    atomic.Int64
+	fail atomic.Bool
+}
+
+func (c *countingChallenge) Generate(opts ChallengeOptions) (Payload, string, error) {
+	if c.fail.Load() {
+		return Payload{}, "", errors.New("generate failed")
+	}
+	answer := strconv.FormatInt(c.n.Add(1), 10)
+	return Payload{Metadata: map[string]string{"difficulty": strconv.Itoa(int(opts.Difficulty))}}, answer, nil
+}
+
+func (c *countingChallenge) Check(record string, answer string) bool {
+	return record == answer
+}
+
+func newCountingRegistry(t *testing.T) (*ChallengeRegistry, *countingChallenge) {
+	gen := &countingChallenge{}
+	r := NewChallengeRegistry()
+	if err := r.Register(ChallengeType{Name: "count", Weight: 1, Generator: gen}); err != nil {
+		t.Fatal(err)
+	}
+	return r, gen
+}
+
+// waitReady 等待池中的题目达到 n 个
+func waitReady(t *testing.T, p *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Ready < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool ready = %d, want %d", p.Stats().Ready, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolGet(t *testing.T) {
+	registry, gen := newCountingRegistry(t)
+	p, err := NewPool(registry, 2, DifficultyPresets("count", ChallengeOptions{}, 3)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	waitReady(t, p, 12)
+
+	stats := p.Stats()
+	if stats.Capacity != 12 || stats.Generated != 12 || gen.n.Load() != 12 {
+		t.Errorf("Stats() = %+v, generated %d, want 12 pre-rendered challenges", stats, gen.n.Load())
+	}
+
+	c, err := p.Get(context.Background(), PoolPresetName("count", CaptchaHard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != "count" || c.Payload.Metadata["difficulty"] != strconv.Itoa(int(CaptchaHard)) {
+		t.Errorf("Get() = %+v, want a hard count challenge", c)
+	}
+	if stats = p.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("Stats() hits = %d, misses = %d, want 1, 0", stats.Hits, stats.Misses)
+	}
+	// 取出后会在后台补充
+	waitReady(t, p, 12)
+
+	if _, err = p.Get(context.Background(), "missing"); !errors.Is(err, ErrUnknownPoolPreset) {
+		t.Errorf("Get() error = %v, want %v", err, ErrUnknownPoolPreset)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = p.Get(ctx, PoolPresetName("count", CaptchaHard)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPoolFallback(t *testing.T) {
+	registry, gen := newCountingRegistry(t)
+	// 后台生成一直失败时，Get 同步生成
+	gen.fail.Store(true)
+	p, err := NewPool(registry, 1, PoolPreset{Type: "count", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Errors == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err = p.Get(context.Background(), "count"); err == nil {
+		t.Error("Get() error = nil, want the generator error")
+	}
+	// worker 在 DefaultPoolRetryDelay 后才会重试
+	gen.fail.Store(false)
+	c, err := p.Get(context.Background(), "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.record == "" {
+		t.Error("Get() returned a challenge without a record")
+	}
+	stats := p.Stats()
+	if stats.Misses != 2 || stats.Hits != 0 || stats.Errors == 0 {
+		t.Errorf("Stats() = %+v, want 2 misses and background errors", stats)
+	}
+	if stats.HitRate() != 0 {
+		t.Errorf("HitRate() = %v, want 0", stats.HitRate())
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	registry, _ := newCountingRegistry(t)
+	p, err := NewPool(registry, 4, PoolPreset{Type: "count", Size: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, p, 8)
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err = p.Get(context.Background(), "count"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Get() error = %v, want %v", err, ErrPoolClosed)
+	}
+	if ready := p.Stats().Ready; ready != 0 {
+		t.Errorf("Stats().Ready = %d after Close, want 0", ready)
+	}
+}
+
+// TestPoolConcurrentText 使用真实的文字题目填充池，配合 go test -race 检查共享的字体族等状态
+func TestPoolConcurrentText(t *testing.T) {
+	p, err := NewPool(nil, 4, DifficultyPresets(ChallengeText, ChallengeOptions{}, 2)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(d CaptchaDifficulty) {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				c, err := p.Get(context.Background(), PoolPresetName(ChallengeText, d))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if c.record == "" {
+					t.Error("Get() returned a challenge without a record")
+				}
+			}
+		}(CaptchaDifficulty(i % (int(CaptchaHard) + 1)))
+	}
+	wg.Wait()
+	if stats := p.Stats(); stats.Hits+stats.Misses != 24 {
+		t.Errorf("Stats() = %+v, want 24 requests", stats)
+	}
+}
+
+func TestNewPoolInvalidPreset(t *testing.T) {
+	registry, _ := newCountingRegistry(t)
+	if _, err := NewPool(registry, 1, PoolPreset{Type: "missing"}); !errors.Is(err, ErrUnknownChallengeType) {
+		t.Errorf("NewPool() error = %v, want %v", err, ErrUnknownChallengeType)
+	}
+	if _, err := NewPool(registry, 1, PoolPreset{Type: "count"}, PoolPreset{Type: "count"}); !errors.Is(err, ErrDuplicatePreset) {
+		t.Errorf("NewPool() error = %v, want %v", err, ErrDuplicatePreset)
+	}
+}
+
+func TestVerifierIssuePooled(t *testing.T) {
+	registry, _ := newCountingRegistry(t)
+	p, err := NewPool(registry, 1, PoolPreset{Type: "count", Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	v := &Verifier{Store: NewMemoryStore(DefaultExpiration), Registry: registry}
+	c, err := v.IssuePooled(context.Background(), "client", p, "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID == "" || c.Expires.IsZero() {
+		t.Errorf("IssuePooled() = %+v, want an ID and expiry", c)
+	}
+	if err = v.Verify("client", c.ID, c.record); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func BenchmarkPoolGet(b *testing.B) {
+	opts := ChallengeOptions{Difficulty: CaptchaMedium}
+	b.Run("direct", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := NewChallenge(nil, ChallengeText, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		p, err := NewPool(nil, 0, PoolPreset{Type: ChallengeText, Options: opts, Size: 256})
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer p.Close()
+		for p.Stats().Ready < 256 {
+			time.Sleep(time.Millisecond)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := p.Get(context.Background(), ChallengeText); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(p.Stats().HitRate(), "hit-rate")
+	})
+}
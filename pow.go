@@ -0,0 +1,214 @@
+package gocaptcha
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultPoWBits 默认的工作量证明难度，浏览器中通常需要几百毫秒
+	DefaultPoWBits = 18
+	// MaxPoWBits 允许的最大难度
+	MaxPoWBits = 32
+	// maxPoWNonceLength nonce 的最大长度，避免校验超长的输入
+	maxPoWNonceLength = 64
+)
+
+var ErrInvalidPoWBits = errors.New("proof of work bits out of range")
+
+// PoWSolverJS 浏览器端的工作量证明求解脚本
+//
+//go:embed pow/solver.js
+var PoWSolverJS []byte
+
+// PoWChallenge 工作量证明题目.
+// 客户端需要找到一个 nonce，使 SHA-256(Prefix + nonce) 的前 Bits 位均为 0.
+type PoWChallenge struct {
+	ID     string `json:"id"`
+	Prefix string `json:"prefix"`
+	Bits   int    `json:"bits"`
+}
+
+// NewPoWChallenge 生成随机前缀和指定难度的题目，ID 为空，需要通过 IssuePoW 保存
+func NewPoWChallenge(difficulty int) (*PoWChallenge, error) {
+	if difficulty < 1 || difficulty > MaxPoWBits {
+		return nil, ErrInvalidPoWBits
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &PoWChallenge{Prefix: hex.EncodeToString(b), Bits: difficulty}, nil
+}
+
+// Check 判断 nonce 是否满足题目的难度要求
+func (c *PoWChallenge) Check(nonce string) bool {
+	if nonce == "" || len(nonce) > maxPoWNonceLength {
+		return false
+	}
+	sum := sha256.Sum256([]byte(c.Prefix + nonce))
+	return leadingZeroBits(sum[:]) >= c.Bits
+}
+
+// Solve 在服务端或非浏览器客户端求解题目，nonce 为十进制计数
+func (c *PoWChallenge) Solve() string {
+	for i := uint64(0); ; i++ {
+		nonce := strconv.FormatUint(i, 10)
+		if c.Check(nonce) {
+			return nonce
+		}
+	}
+}
+
+// record 返回保存到 store 中的记录，格式为 "bits:prefix"
+func (c *PoWChallenge) record() string {
+	return strconv.Itoa(c.Bits) + ":" + c.Prefix
+}
+
+// parsePoWRecord 解析 store 中保存的题目记录
+func parsePoWRecord(record string) (*PoWChallenge, bool) {
+	b, prefix, ok := strings.Cut(record, ":")
+	if !ok {
+		return nil, false
+	}
+	n, err := strconv.Atoi(b)
+	if err != nil {
+		return nil, false
+	}
+	return &PoWChallenge{Prefix: prefix, Bits: n}, true
+}
+
+// leadingZeroBits 返回 sum 开头连续为 0 的位数
+func leadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// IssuePoW 生成工作量证明题目并保存到 store.
+// difficulty 可按请求调整，例如对可疑的客户端提高难度.
+// 记录的类型为 ChallengePoW，只能通过 VerifyPoW 或 Verifier 校验.
+func IssuePoW(store Store, difficulty int) (*PoWChallenge, error) {
+	c, err := NewPoWChallenge(difficulty)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, err = (&Verifier{Store: store}).issue(verifyRecord{Type: ChallengePoW, Answer: c.record()})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// VerifyPoW 校验客户端提交的 nonce，题目只能校验一次
+func VerifyPoW(store Store, id string, nonce string) bool {
+	value, err := store.Get(id, true)
+	if err != nil {
+		return false
+	}
+	record, ok := parseVerifyRecord(value)
+	if !ok || record.Type != ChallengePoW {
+		return false
+	}
+	c, ok := parsePoWRecord(record.Answer)
+	if !ok {
+		return false
+	}
+	return c.Check(nonce)
+}
+
+// PoWHandler 工作量证明的 HTTP 处理器.
+//
+//	GET  .../solver.js   返回求解脚本 PoWSolverJS
+//	GET  ...             返回 JSON 格式的 PoWChallenge
+//	POST ...             校验表单中的 id 和 nonce，返回 {"success": true|false}
+type PoWHandler struct {
+	// Store 保存题目的存储，为空时使用 DefaultStore
+	Store Store
+	// Bits 题目难度，为 0 时使用 DefaultPoWBits
+	Bits int
+	// Difficulty 按请求计算难度，不为空时优先于 Bits，用于自适应难度
+	Difficulty func(r *http.Request) int
+	// Limiter 按客户端限制获取和校验题目的频率，为空时不限制
+	Limiter Limiter
+	// ClientKey 返回请求的客户端 key，为空时使用 ClientIP
+	ClientKey func(r *http.Request) string
+}
+
+// NewPoWHandler 创建使用固定难度的工作量证明处理器
+func NewPoWHandler(store Store, difficulty int) *PoWHandler {
+	return &PoWHandler{Store: store, Bits: difficulty}
+}
+
+// ServeHTTP 实现 http.Handler
+func (h *PoWHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	store := h.Store
+	if store == nil {
+		store = DefaultStore
+	}
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/solver.js"):
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		_, _ = w.Write(PoWSolverJS)
+	case r.Method == http.MethodGet:
+		if !h.admit(w, r) {
+			return
+		}
+		difficulty := h.Bits
+		if h.Difficulty != nil {
+			difficulty = h.Difficulty(r)
+		}
+		if difficulty == 0 {
+			difficulty = DefaultPoWBits
+		}
+		c, err := IssuePoW(store, difficulty)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, c)
+	case r.Method == http.MethodPost:
+		if !h.admit(w, r) {
+			return
+		}
+		ok := VerifyPoW(store, r.FormValue("id"), r.FormValue("nonce"))
+		writeJSON(w, map[string]bool{"success": ok})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// admit 按客户端限制请求频率，超出限制时通过 WriteError 返回 429 并返回 false
+func (h *PoWHandler) admit(w http.ResponseWriter, r *http.Request) bool {
+	clientKey := h.ClientKey
+	if clientKey == nil {
+		clientKey = ClientIP
+	}
+	if err := allow(h.Limiter, clientKey(r)); err != nil {
+		WriteError(w, err)
+		return false
+	}
+	return true
+}
+
+// writeJSON 以 JSON 格式写出响应，禁止缓存
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,132 @@
+package gocaptcha
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		name string
+		sum  []byte
+		want int
+	}{
+		{name: "none", sum: []byte{0x80, 0}, want: 0},
+		{name: "partial byte", sum: []byte{0x1f, 0xff}, want: 3},
+		{name: "full byte", sum: []byte{0, 0x40}, want: 9},
+		{name: "all zero", sum: []byte{0, 0}, want: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := leadingZeroBits(tt.sum); got != tt.want {
+				t.Errorf("leadingZeroBits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPoWChallengeInvalidBits(t *testing.T) {
+	for _, bits := range []int{0, -1, MaxPoWBits + 1} {
+		if _, err := NewPoWChallenge(bits); err != ErrInvalidPoWBits {
+			t.Errorf("NewPoWChallenge(%d) error = %v, want %v", bits, err, ErrInvalidPoWBits)
+		}
+	}
+}
+
+func TestIssueVerifyPoW(t *testing.T) {
+	store := NewMemoryStore(DefaultExpiration)
+	c, err := IssuePoW(store, 10)
+	if err != nil {
+		t.Fatalf("IssuePoW() error = %v", err)
+	}
+	nonce := c.Solve()
+	if !c.Check(nonce) {
+		t.Fatalf("Check(%q) = false for solved nonce", nonce)
+	}
+	if c.Check(strings.Repeat("1", maxPoWNonceLength+1)) {
+		t.Error("Check() accepted an oversized nonce")
+	}
+	if !VerifyPoW(store, c.ID, nonce) {
+		t.Error("VerifyPoW() = false, want true")
+	}
+	if VerifyPoW(store, c.ID, nonce) {
+		t.Error("VerifyPoW() succeeded twice")
+	}
+
+	c, _ = IssuePoW(store, 20)
+	bad := "not-a-solution"
+	if c.Check(bad) {
+		t.Skip("random prefix happens to accept the wrong nonce")
+	}
+	if VerifyPoW(store, c.ID, bad) {
+		t.Error("VerifyPoW() accepted a wrong nonce")
+	}
+}
+
+func TestPoWRecordIsNotATextAnswer(t *testing.T) {
+	store := NewMemoryStore(DefaultExpiration)
+	c, err := IssuePoW(store, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 客户端知道 bits 和 prefix，不能把它们作为文本答案提交
+	if Verify(store, c.ID, strconv.Itoa(c.Bits)+":"+c.Prefix) {
+		t.Error("text Verify() accepted the public PoW record")
+	}
+	c, _ = IssuePoW(store, 20)
+	if VerifyCharset(store, c.ID, strconv.Itoa(c.Bits)+":"+c.Prefix, CharsetLatinSafe) {
+		t.Error("VerifyCharset() accepted the public PoW record")
+	}
+
+	// 文本答案也不能作为工作量证明题目校验
+	id, _ := Issue(store, "1:prefix")
+	if VerifyPoW(store, id, (&PoWChallenge{Prefix: "prefix", Bits: 1}).Solve()) {
+		t.Error("VerifyPoW() accepted a text record")
+	}
+}
+
+func TestPoWHandler(t *testing.T) {
+	store := NewMemoryStore(DefaultExpiration)
+	h := &PoWHandler{Store: store, Difficulty: func(r *http.Request) int {
+		if r.URL.Query().Get("suspicious") != "" {
+			return 12
+		}
+		return 8
+	}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pow/solver.js", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") || rec.Body.Len() != len(PoWSolverJS) {
+		t.Errorf("solver.js: content type %q, %d bytes", ct, rec.Body.Len())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pow?suspicious=1", nil))
+	var c PoWChallenge
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatalf("decode challenge: %v", err)
+	}
+	if c.ID == "" || c.Bits != 12 || len(c.Prefix) != 32 {
+		t.Fatalf("challenge = %+v", c)
+	}
+
+	form := url.Values{"id": {c.ID}, "nonce": {c.Solve()}}
+	req := httptest.NewRequest(http.MethodPost, "/pow", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"success":true}` {
+		t.Errorf("verify response = %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/pow", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,143 @@
+package gocaptcha
+
+import (
+	"embed"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"sync"
+)
+
+//go:embed profanity/*.txt
+var embeddedProfanity embed.FS
+
+// maxFilterRetries 过滤后重新生成答案的最大次数
+const maxFilterRetries = 100
+
+var ErrAnswerFiltered = errors.New("too many generated answers were filtered")
+
+// DefaultProfanityList 内置的多语言敏感词列表
+var DefaultProfanityList = loadProfanityList()
+
+// DefaultProfanityFilter 使用内置敏感词列表的过滤器
+var DefaultProfanityFilter = NewProfanityFilter(DefaultProfanityList)
+
+// leetReplacer 将 leetspeak 和形近字符折叠为同一个字母，例如 0→o、1→i、5→s.
+// l 与 i 形近，统一折叠为 i.
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"l", "i",
+	"!", "i",
+	"|", "i",
+	"3", "e",
+	"4", "a",
+	"@", "a",
+	"5", "s",
+	"$", "s",
+	"7", "t",
+	"8", "b",
+	"9", "g",
+)
+
+// foldLeet 转为小写并折叠 leetspeak 字符
+func foldLeet(s string) string {
+	return leetReplacer.Replace(strings.ToLower(s))
+}
+
+// ProfanityFilter 敏感子串过滤器，匹配时忽略大小写并折叠 leetspeak
+type ProfanityFilter struct {
+	mu    sync.RWMutex
+	words []string
+}
+
+// Add 添加敏感词
+func (f *ProfanityFilter) Add(words ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, word := range words {
+		word = foldLeet(strings.TrimSpace(word))
+		if word != "" {
+			f.words = append(f.words, word)
+		}
+	}
+}
+
+// AddList 从 r 中按行读取敏感词，忽略空行和 # 开头的注释
+func (f *ProfanityFilter) AddList(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.Add(loadWordList(data)...)
+	return nil
+}
+
+// Contains 判断 s 是否包含任一敏感子串
+func (f *ProfanityFilter) Contains(s string) bool {
+	s = foldLeet(s)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, word := range f.words {
+		if strings.Contains(s, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProfanityFilter 使用给定的敏感词列表创建过滤器
+func NewProfanityFilter(lists ...[]string) *ProfanityFilter {
+	f := &ProfanityFilter{}
+	for _, list := range lists {
+		f.Add(list...)
+	}
+	return f
+}
+
+// loadProfanityList 读取内置的全部敏感词列表
+func loadProfanityList() []string {
+	var words []string
+	entries, _ := embeddedProfanity.ReadDir("profanity")
+	for _, entry := range entries {
+		data, err := embeddedProfanity.ReadFile(path.Join("profanity", entry.Name()))
+		if err != nil {
+			continue
+		}
+		words = append(words, loadWordList(data)...)
+	}
+	return words
+}
+
+type filteredGenerator struct {
+	generator AnswerGenerator
+	filter    *ProfanityFilter
+}
+
+// Generate 生成答案，包含敏感子串时丢弃并重新生成
+func (g *filteredGenerator) Generate(length int) (string, error) {
+	for i := 0; i < maxFilterRetries; i++ {
+		answer, err := g.generator.Generate(length)
+		if err != nil {
+			return "", err
+		}
+		if !g.filter.Contains(answer) {
+			return answer, nil
+		}
+	}
+	return "", ErrAnswerFiltered
+}
+
+// Entropy 返回被包装生成器的熵，过滤掉的答案占比很小，可忽略不计
+func (g *filteredGenerator) Entropy(length int) float64 {
+	return g.generator.Entropy(length)
+}
+
+// NewFilteredGenerator 返回一个过滤敏感答案的生成器，filter 为空时使用 DefaultProfanityFilter
+func NewFilteredGenerator(generator AnswerGenerator, filter *ProfanityFilter) AnswerGenerator {
+	if filter == nil {
+		filter = DefaultProfanityFilter
+	}
+	return &filteredGenerator{generator: generator, filter: filter}
+}
@@ -0,0 +1,107 @@
+package gocaptcha
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfanityFilter_Contains(t *testing.T) {
+	filter := NewProfanityFilter([]string{"shit", "ass"})
+	tests := []struct {
+		name string
+		s    string
+		want bool
+	}{
+		{name: "plain", s: "xshitx", want: true},
+		{name: "upper case", s: "SHIT", want: true},
+		{name: "leetspeak", s: "5H1T", want: true},
+		{name: "leetspeak l", s: "sh!t", want: true},
+		{name: "dollar and at", s: "@$$", want: true},
+		{name: "zero", s: "a55", want: true},
+		{name: "clean", s: "aBc3", want: false},
+		{name: "empty", s: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Contains(tt.s); got != tt.want {
+				t.Errorf("ProfanityFilter.Contains(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfanityFilter_AddList(t *testing.T) {
+	filter := NewProfanityFilter()
+	if filter.Contains("badword") {
+		t.Fatal("empty filter should not match")
+	}
+	err := filter.AddList(strings.NewReader("# comment\n\nbadword\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Contains("xxBADW0RDxx") {
+		t.Errorf("ProfanityFilter.Contains() = false after AddList")
+	}
+	if filter.Contains("comment") {
+		t.Errorf("ProfanityFilter.Contains() matched a comment line")
+	}
+}
+
+func TestDefaultProfanityFilter(t *testing.T) {
+	for _, s := range []string{"fUcK", "5hit", "傻逼", "mierda", "Fick", "merde", "blyat", "сука", "porra"} {
+		if !DefaultProfanityFilter.Contains(s) {
+			t.Errorf("DefaultProfanityFilter.Contains(%q) = false, want true", s)
+		}
+	}
+}
+
+type fixedGenerator struct {
+	answers []string
+	i       int
+}
+
+func (g *fixedGenerator) Generate(length int) (string, error) {
+	answer := g.answers[g.i%len(g.answers)]
+	g.i++
+	return answer, nil
+}
+
+func (g *fixedGenerator) Entropy(length int) float64 {
+	return 1
+}
+
+func TestFilteredGenerator_Generate(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []string
+		want    string
+		wantErr bool
+	}{
+		{name: "clean", answers: []string{"abcd"}, want: "abcd"},
+		{name: "regenerate", answers: []string{"fuck", "5h1t", "abcd"}, want: "abcd"},
+		{name: "always filtered", answers: []string{"fuck"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewFilteredGenerator(&fixedGenerator{answers: tt.answers}, nil)
+			got, err := g.Generate(4)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filteredGenerator.Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("filteredGenerator.Generate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultWordListIsClean(t *testing.T) {
+	g := NewWordListGenerator(nil).(*wordListGenerator)
+	for _, words := range g.byLength {
+		for _, word := range words {
+			if DefaultProfanityFilter.Contains(word) {
+				t.Errorf("word list contains %q", word)
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package gocaptcha
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 内置的验证码类型名称
+const (
+	ChallengeText     = "text"
+	ChallengeColor    = "color"
+	ChallengeClock    = "clock"
+	ChallengeDice     = "dice"
+	ChallengeOddGlyph = "odd-glyph"
+	ChallengeCJK      = "cjk"
+	ChallengePoW      = "pow"
+)
+
+var (
+	ErrDuplicateChallengeType = errors.New("challenge type already registered")
+	ErrNoChallengeType        = errors.New("no challenge type matches the request")
+)
+
+// Capability 验证码类型对用户和设备的要求
+type Capability uint
+
+const (
+	// CapabilityVisual 需要看清图片
+	CapabilityVisual Capability = 1 << iota
+	// CapabilityColor 需要分辨颜色
+	CapabilityColor
+	// CapabilityAudio 可以通过声音完成
+	CapabilityAudio
+	// CapabilityKeyboard 需要输入答案
+	CapabilityKeyboard
+	// CapabilityPointer 需要点击或拖动
+	CapabilityPointer
+	// CapabilityTouch 适用于触屏设备
+	CapabilityTouch
+	// CapabilityMouse 适用于鼠标设备
+	CapabilityMouse
+	// CapabilityInvisible 无需用户操作，例如工作量证明
+	CapabilityInvisible
+)
+
+// DeviceClass 客户端的输入设备
+type DeviceClass int
+
+const (
+	DeviceUnknown DeviceClass = iota
+	DeviceMouse
+	DeviceTouch
+)
+
+// Accessibility 用户的无障碍偏好
+type Accessibility int
+
+const (
+	AccessibilityNone Accessibility = iota
+	// AccessibilityNonVisual 使用读屏软件，只能使用声音或无感验证
+	AccessibilityNonVisual
+	// AccessibilityColorBlind 色觉障碍，不使用依赖颜色的验证码
+	AccessibilityColorBlind
+)
+
+// ChallengeType 验证码类型及其能力声明
+type ChallengeType struct {
+	// Name 类型名称
+	Name string
+	// Capabilities 对用户和设备的要求
+	Capabilities Capability
+	// Locales 支持的语言，为空时支持全部语言
+	Locales []string
+	// Weight 默认权重
+	Weight float64
+	// Generator 生成和校验题目，为空时该类型只参与选择，由调用方自行生成
+	Generator ChallengeGenerator
+}
+
+// Has 判断是否具备全部 c 能力
+func (t ChallengeType) Has(c Capability) bool {
+	return t.Capabilities&c == c
+}
+
+// SupportsLocale 判断是否支持 locale，"zh-CN" 可以匹配 "zh"
+func (t ChallengeType) SupportsLocale(locale string) bool {
+	if len(t.Locales) == 0 {
+		return true
+	}
+	lang := localeLanguage(locale)
+	for _, l := range t.Locales {
+		if strings.EqualFold(l, locale) || strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// localeLanguage 返回 locale 的语言部分，例如 "zh-CN" 返回 "zh"
+func localeLanguage(locale string) string {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// ChallengeRegistry 验证码类型的注册表
+type ChallengeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]ChallengeType
+}
+
+// NewChallengeRegistry 创建空的注册表
+func NewChallengeRegistry() *ChallengeRegistry {
+	return &ChallengeRegistry{types: make(map[string]ChallengeType)}
+}
+
+// Register 注册验证码类型，名称重复时返回 ErrDuplicateChallengeType
+func (r *ChallengeRegistry) Register(t ChallengeType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.types[t.Name]; ok {
+		return ErrDuplicateChallengeType
+	}
+	r.types[t.Name] = t
+	return nil
+}
+
+// Lookup 按名称查找验证码类型
+func (r *ChallengeRegistry) Lookup(name string) (ChallengeType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Types 返回按名称排序的全部验证码类型
+func (r *ChallengeRegistry) Types() []ChallengeType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]ChallengeType, 0, len(r.types))
+	for _, t := range r.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// DefaultChallengeRegistry 注册了内置验证码类型的注册表
+var DefaultChallengeRegistry = newDefaultChallengeRegistry()
+
+func newDefaultChallengeRegistry() *ChallengeRegistry {
+	r := NewChallengeRegistry()
+	anyDevice := CapabilityTouch | CapabilityMouse
+	for _, t := range []ChallengeType{
+		{Name: ChallengeText, Capabilities: CapabilityVisual | CapabilityKeyboard | anyDevice, Weight: 1, Generator: textChallenge{}},
+		{Name: ChallengeColor, Capabilities: CapabilityVisual | CapabilityColor | CapabilityKeyboard | anyDevice, Locales: []string{"en", "zh"}, Weight: 0.5, Generator: colorChallenge{}},
+		{Name: ChallengeClock, Capabilities: CapabilityVisual | CapabilityKeyboard | anyDevice, Weight: 0.5, Generator: clockChallenge{}},
+		{Name: ChallengeDice, Capabilities: CapabilityVisual | CapabilityKeyboard | anyDevice, Weight: 0.5, Generator: diceChallenge{}},
+		{Name: ChallengeOddGlyph, Capabilities: CapabilityVisual | CapabilityPointer | anyDevice, Weight: 0.5, Generator: oddGlyphChallenge{}},
+		{Name: ChallengeCJK, Capabilities: CapabilityVisual | CapabilityKeyboard | anyDevice, Locales: []string{"zh"}, Weight: 1, Generator: cjkChallenge{}},
+		{Name: ChallengePoW, Capabilities: CapabilityInvisible | anyDevice, Weight: 1, Generator: powChallenge{}},
+	} {
+		_ = r.Register(t)
+	}
+	return r
+}
+
+// RequestSignals 选择验证码类型时参考的请求信息
+type RequestSignals struct {
+	// Risk 风险分数，取值范围 0~1，见 RiskScore
+	Risk float64
+	// Device 输入设备
+	Device DeviceClass
+	// Accessibility 无障碍偏好
+	Accessibility Accessibility
+	// Locale 语言，例如 "zh-CN"
+	Locale string
+}
+
+// SelectionRule 风险分数位于 [MinRisk, MaxRisk) 时使用的权重，MaxRisk 为 1 时包含 1 及以上的分数.
+// 未列出的类型使用其默认权重，权重为 0 的类型不会被选中.
+type SelectionRule struct {
+	MinRisk float64
+	MaxRisk float64
+	Weights map[string]float64
+}
+
+// DefaultSelectionRules 低风险优先使用无感验证，高风险优先使用点选等较难自动化的验证码
+var DefaultSelectionRules = []SelectionRule{
+	{MinRisk: 0, MaxRisk: 0.3, Weights: map[string]float64{ChallengePoW: 4, ChallengeOddGlyph: 0}},
+	{MinRisk: 0.3, MaxRisk: 0.7, Weights: map[string]float64{ChallengePoW: 0.2}},
+	{MinRisk: 0.7, MaxRisk: 1, Weights: map[string]float64{ChallengePoW: 0, ChallengeText: 0.5, ChallengeOddGlyph: 2, ChallengeColor: 1}},
+}
+
+// SelectionPolicy 按请求信息在注册表中加权随机选择验证码类型
+type SelectionPolicy struct {
+	// Registry 验证码类型注册表，为空时使用 DefaultChallengeRegistry
+	Registry *ChallengeRegistry
+	// Rules 按风险分数划分的权重，为空时使用 DefaultSelectionRules
+	Rules []SelectionRule
+}
+
+// NewSelectionPolicy 使用默认规则创建选择策略
+func NewSelectionPolicy(registry *ChallengeRegistry) *SelectionPolicy {
+	return &SelectionPolicy{Registry: registry}
+}
+
+// Candidates 返回满足设备、无障碍和语言要求的验证码类型及其权重
+func (p *SelectionPolicy) Candidates(s RequestSignals) ([]ChallengeType, []float64) {
+	registry := p.Registry
+	if registry == nil {
+		registry = DefaultChallengeRegistry
+	}
+	rules := p.Rules
+	if rules == nil {
+		rules = DefaultSelectionRules
+	}
+	var weights map[string]float64
+	for _, rule := range rules {
+		if s.Risk >= rule.MinRisk && (s.Risk < rule.MaxRisk || rule.MaxRisk >= 1) {
+			weights = rule.Weights
+			break
+		}
+	}
+
+	var types []ChallengeType
+	var typeWeights []float64
+	for _, t := range registry.Types() {
+		if !eligible(t, s) {
+			continue
+		}
+		w, ok := weights[t.Name]
+		if !ok {
+			w = t.Weight
+		}
+		types = append(types, t)
+		typeWeights = append(typeWeights, w)
+	}
+	return types, typeWeights
+}
+
+// eligible 判断验证码类型是否适用于请求.
+// Generator 实现了 Available() bool 且返回 false 时，例如汉字验证码未加载字体，该类型不会被选中.
+func eligible(t ChallengeType, s RequestSignals) bool {
+	if a, ok := t.Generator.(interface{ Available() bool }); ok && !a.Available() {
+		return false
+	}
+	switch s.Device {
+	case DeviceTouch:
+		if !t.Has(CapabilityTouch) {
+			return false
+		}
+	case DeviceMouse:
+		if !t.Has(CapabilityMouse) {
+			return false
+		}
+	}
+	switch s.Accessibility {
+	case AccessibilityNonVisual:
+		if !t.Has(CapabilityAudio) && !t.Has(CapabilityInvisible) {
+			return false
+		}
+	case AccessibilityColorBlind:
+		if t.Has(CapabilityColor) {
+			return false
+		}
+	}
+	return s.Locale == "" || t.SupportsLocale(s.Locale)
+}
+
+// Allows 判断 Select 对请求 s 是否可能选中名称为 name 的类型
+func (p *SelectionPolicy) Allows(s RequestSignals, name string) bool {
+	types, weights := p.Candidates(s)
+	weighted := false
+	for _, w := range weights {
+		if w > 0 {
+			weighted = true
+			break
+		}
+	}
+	for i, t := range types {
+		if t.Name == name {
+			return weights[i] > 0 || !weighted
+		}
+	}
+	return false
+}
+
+// Select 按权重随机选择验证码类型.
+// 符合条件的类型权重都为 0 时在其中均匀选择，避免有无障碍需求的用户无法通过验证.
+func (p *SelectionPolicy) Select(s RequestSignals) (ChallengeType, error) {
+	types, weights := p.Candidates(s)
+	if len(types) == 0 {
+		return ChallengeType{}, ErrNoChallengeType
+	}
+	var total float64
+	for _, w := range weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total == 0 {
+		return types[rand.Intn(len(types))], nil
+	}
+	x := rand.Float64() * total
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if x < w {
+			return types[i], nil
+		}
+		x -= w
+	}
+	// 浮点误差时返回最后一个权重大于 0 的类型
+	for i := len(types) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return types[i], nil
+		}
+	}
+	return types[0], nil
+}
@@ -0,0 +1,139 @@
+package gocaptcha
+
+import (
+	"testing"
+)
+
+func TestChallengeRegistry(t *testing.T) {
+	r := NewChallengeRegistry()
+	if err := r.Register(ChallengeType{Name: "slider", Capabilities: CapabilityPointer, Weight: 1}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := r.Register(ChallengeType{Name: "slider"}); err != ErrDuplicateChallengeType {
+		t.Errorf("Register() duplicate error = %v, want %v", err, ErrDuplicateChallengeType)
+	}
+	if _, ok := r.Lookup("slider"); !ok {
+		t.Error("Lookup() did not find registered type")
+	}
+	if _, ok := r.Lookup("audio"); ok {
+		t.Error("Lookup() found unregistered type")
+	}
+	for _, level := range DefaultChallengeLevels {
+		if _, ok := DefaultChallengeRegistry.Lookup(level.Challenge); level.Challenge != "" && !ok {
+			t.Errorf("adaptive level uses unregistered challenge %q", level.Challenge)
+		}
+	}
+}
+
+func TestChallengeTypeSupportsLocale(t *testing.T) {
+	ct := ChallengeType{Locales: []string{"zh", "pt-BR"}}
+	tests := []struct {
+		locale string
+		want   bool
+	}{
+		{"zh", true},
+		{"zh-CN", true},
+		{"zh_TW", true},
+		{"pt-BR", true},
+		{"pt-PT", false},
+		{"en", false},
+	}
+	for _, tt := range tests {
+		if got := ct.SupportsLocale(tt.locale); got != tt.want {
+			t.Errorf("SupportsLocale(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestSelectionPolicyCandidates(t *testing.T) {
+	p := NewSelectionPolicy(nil)
+	names := func(s RequestSignals) map[string]float64 {
+		types, weights := p.Candidates(s)
+		m := make(map[string]float64)
+		for i, ct := range types {
+			m[ct.Name] = weights[i]
+		}
+		return m
+	}
+
+	got := names(RequestSignals{Locale: "en-US"})
+	if _, ok := got[ChallengeCJK]; ok {
+		t.Error("CJK challenge offered to an English locale")
+	}
+	if got[ChallengePoW] != 4 || got[ChallengeOddGlyph] != 0 {
+		t.Errorf("low risk weights = %v", got)
+	}
+
+	got = names(RequestSignals{Risk: 1, Locale: "zh-CN"})
+	if got[ChallengeOddGlyph] != 2 || got[ChallengePoW] != 0 {
+		t.Errorf("high risk weights = %v", got)
+	}
+	// 未加载汉字字体时不会选中汉字验证码
+	if _, ok := got[ChallengeCJK]; ok != (len(CJKFontFamily.fonts) > 0) {
+		t.Errorf("CJK challenge offered = %v with %d CJK fonts", ok, len(CJKFontFamily.fonts))
+	}
+
+	got = names(RequestSignals{Accessibility: AccessibilityColorBlind})
+	if _, ok := got[ChallengeColor]; ok {
+		t.Error("color challenge offered to a color blind user")
+	}
+
+	got = names(RequestSignals{Accessibility: AccessibilityNonVisual, Risk: 0.9})
+	if len(got) != 1 || got[ChallengePoW] != 0 {
+		t.Errorf("non-visual candidates = %v, want only pow", got)
+	}
+}
+
+func TestSelectionPolicySelect(t *testing.T) {
+	r := NewChallengeRegistry()
+	r.Register(ChallengeType{Name: "slider", Capabilities: CapabilityPointer | CapabilityTouch, Weight: 1})
+	r.Register(ChallengeType{Name: "text", Capabilities: CapabilityKeyboard | CapabilityTouch | CapabilityMouse, Weight: 3})
+	r.Register(ChallengeType{Name: "audio", Capabilities: CapabilityAudio | CapabilityMouse, Weight: 0})
+	p := &SelectionPolicy{Registry: r, Rules: []SelectionRule{}}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		ct, err := p.Select(RequestSignals{Device: DeviceTouch})
+		if err != nil {
+			t.Fatalf("Select() error = %v", err)
+		}
+		counts[ct.Name]++
+	}
+	if counts["audio"] != 0 {
+		t.Errorf("audio selected %d times for a touch device", counts["audio"])
+	}
+	if ratio := float64(counts["text"]) / float64(counts["slider"]); ratio < 2.5 || ratio > 3.5 {
+		t.Errorf("text/slider ratio = %.2f, want about 3", ratio)
+	}
+
+	// 只剩权重为 0 的类型时仍然可以选中
+	if ct, err := p.Select(RequestSignals{Accessibility: AccessibilityNonVisual}); err != nil || ct.Name != "audio" {
+		t.Errorf("Select() = %v, %v, want audio", ct.Name, err)
+	}
+	if _, err := p.Select(RequestSignals{Device: DeviceTouch, Accessibility: AccessibilityNonVisual}); err != ErrNoChallengeType {
+		t.Errorf("Select() error = %v, want %v", err, ErrNoChallengeType)
+	}
+}
+
+func TestSelectionPolicyAllows(t *testing.T) {
+	p := NewSelectionPolicy(nil)
+	tests := []struct {
+		signals RequestSignals
+		name    string
+		want    bool
+	}{
+		{RequestSignals{}, ChallengePoW, true},
+		{RequestSignals{Risk: 0.9}, ChallengePoW, false},
+		{RequestSignals{Risk: 0.9}, ChallengeOddGlyph, true},
+		{RequestSignals{}, ChallengeOddGlyph, false},
+		{RequestSignals{Locale: "en"}, ChallengeCJK, false},
+		{RequestSignals{}, "audio", false},
+		// 只剩权重为 0 的类型时这些类型都可能被选中
+		{RequestSignals{Risk: 0.9, Accessibility: AccessibilityNonVisual}, ChallengePoW, true},
+	}
+	for _, tt := range tests {
+		if got := p.Allows(tt.signals, tt.name); got != tt.want {
+			t.Errorf("Allows(%+v, %q) = %v, want %v", tt.signals, tt.name, got, tt.want)
+		}
+	}
+}
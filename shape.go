@@ -0,0 +1,56 @@
+package gocaptcha
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// drawDisk 以 (cx, cy) 为圆心绘制半径为 radius 的实心圆
+func drawDisk(canvas draw.Image, cx, cy int, radius int, c color.Color) {
+	bounds := canvas.Bounds()
+	setter := newPixelSetter(canvas, c)
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			if image.Pt(cx+dx, cy+dy).In(bounds) {
+				setter.set(cx+dx, cy+dy)
+			}
+		}
+	}
+}
+
+// drawThickLine 绘制宽度为 width 的线段
+func drawThickLine(canvas draw.Image, p0 image.Point, p1 image.Point, width int, c color.Color) {
+	radius := width / 2
+	steps := int(math.Max(math.Abs(float64(p1.X-p0.X)), math.Abs(float64(p1.Y-p0.Y))))
+	if steps == 0 {
+		drawDisk(canvas, p0.X, p0.Y, radius, c)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Round(float64(p0.X) + t*float64(p1.X-p0.X)))
+		y := int(math.Round(float64(p0.Y) + t*float64(p1.Y-p0.Y)))
+		drawDisk(canvas, x, y, radius, c)
+	}
+}
+
+// drawRing 绘制圆环
+func drawRing(canvas draw.Image, cx, cy int, radius int, width int, c color.Color) {
+	bounds := canvas.Bounds()
+	inner := float64(radius - width)
+	outer := float64(radius)
+	setter := newPixelSetter(canvas, c)
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			d := math.Hypot(float64(x-cx), float64(y-cy))
+			if d >= inner && d <= outer && image.Pt(x, y).In(bounds) {
+				setter.set(x, y)
+			}
+		}
+	}
+}
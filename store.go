@@ -0,0 +1,146 @@
+package gocaptcha
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExpiration 验证码默认的有效期
+const DefaultExpiration = 10 * time.Minute
+
+var ErrCaptchaNotFound = errors.New("captcha not found")
+
+// DefaultStore 默认的内存存储
+var DefaultStore = NewMemoryStore(DefaultExpiration)
+
+// Store 验证码答案的存储接口
+type Store interface {
+	// Set 保存验证码 id 对应的答案
+	Set(id string, answer string) error
+	// Get 获取验证码 id 对应的答案，clear 为 true 时同时删除该记录
+	Get(id string, clear bool) (string, error)
+}
+
+type memoryItem struct {
+	answer  string
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu         sync.Mutex
+	items      map[string]memoryItem
+	expiration time.Duration
+	lastSweep  time.Time
+}
+
+// Set 保存答案，并顺带清理已过期的记录
+func (s *memoryStore) Set(id string, answer string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > s.expiration {
+		for k, item := range s.items {
+			if now.After(item.expires) {
+				delete(s.items, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.items[id] = memoryItem{answer: answer, expires: now.Add(s.expiration)}
+	return nil
+}
+
+// Get 获取答案，过期的记录视为不存在
+func (s *memoryStore) Get(id string, clear bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return "", ErrCaptchaNotFound
+	}
+	expired := time.Now().After(item.expires)
+	if clear || expired {
+		delete(s.items, id)
+	}
+	if expired {
+		return "", ErrCaptchaNotFound
+	}
+	return item.answer, nil
+}
+
+// NewMemoryStore 创建一个内存存储，记录在 expiration 之后过期
+func NewMemoryStore(expiration time.Duration) Store {
+	if expiration <= 0 {
+		expiration = DefaultExpiration
+	}
+	return &memoryStore{
+		items:      make(map[string]memoryItem),
+		expiration: expiration,
+		lastSweep:  time.Now(),
+	}
+}
+
+var idCharacters = []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789")
+
+// NewCaptchaID 生成一个随机的验证码 ID
+func NewCaptchaID() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = idCharacters[int(b[i])%len(idCharacters)]
+	}
+	return string(b)
+}
+
+// Issue 为答案生成新的验证码 ID 并保存到 store
+func Issue(store Store, answer string) (id string, err error) {
+	id = NewCaptchaID()
+	if err = store.Set(id, answer); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// getAnswer 取出并删除 Issue 保存的答案.
+// Verifier 和 IssuePoW 保存的记录带有类型，不能作为普通答案校验，否则客户端可以直接提交
+// 工作量证明题目公开的 "bits:prefix" 通过校验.
+func getAnswer(store Store, id string) (string, error) {
+	value, err := store.Get(id, true)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := parseVerifyRecord(value); ok {
+		return "", ErrCaptchaNotFound
+	}
+	return value, nil
+}
+
+// Verify 校验用户输入的答案，不区分大小写，忽略首尾空白.
+// 无论结果如何，验证码都只能校验一次.
+func Verify(store Store, id string, answer string) bool {
+	want, err := getAnswer(store, id)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(answer), want)
+}
+
+// IssueCaptcha 生成文本验证码并将答案保存到 store，返回验证码 ID 和图片数据
+func IssueCaptcha(store Store, width, height int, textLength int, difficulty CaptchaDifficulty) (id string, imgBytes []byte, err error) {
+	text, imgBytes, err := GenerateCaptcha(width, height, textLength, difficulty)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err = Issue(store, text)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, imgBytes, nil
+}
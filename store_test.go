@@ -0,0 +1,89 @@
+package gocaptcha
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	if err := store.Set("id1", "abcd"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		id      string
+		clear   bool
+		want    string
+		wantErr bool
+	}{
+		{name: "get", id: "id1", clear: false, want: "abcd"},
+		{name: "get and clear", id: "id1", clear: true, want: "abcd"},
+		{name: "cleared", id: "id1", clear: false, wantErr: true},
+		{name: "not found", id: "id2", clear: false, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Get(tt.id, tt.clear)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("memoryStore.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("memoryStore.Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Expiration(t *testing.T) {
+	store := NewMemoryStore(10 * time.Millisecond)
+	_ = store.Set("id1", "abcd")
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Get("id1", false); err != ErrCaptchaNotFound {
+		t.Errorf("memoryStore.Get() error = %v, want %v", err, ErrCaptchaNotFound)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		input  string
+		want   bool
+	}{
+		{name: "exact", answer: "aBcD", input: "aBcD", want: true},
+		{name: "case insensitive", answer: "aBcD", input: "ABCD", want: true},
+		{name: "spaces", answer: "aBcD", input: " abcd ", want: true},
+		{name: "wrong", answer: "aBcD", input: "abce", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore(DefaultExpiration)
+			id, err := Issue(store, tt.answer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Verify(store, id, tt.input); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+			// 验证码只能校验一次
+			if Verify(store, id, tt.answer) {
+				t.Errorf("Verify() succeeded twice")
+			}
+		})
+	}
+}
+
+func TestIssueCaptcha(t *testing.T) {
+	id, imgBytes, err := IssueCaptcha(DefaultStore, 180, 60, 4, CaptchaMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) == 0 || len(imgBytes) == 0 {
+		t.Errorf("IssueCaptcha() id = %v, len(imgBytes) = %d", id, len(imgBytes))
+	}
+	answer, err := DefaultStore.Get(id, false)
+	if err != nil || len(answer) != 4 {
+		t.Errorf("IssueCaptcha() answer = %v, err = %v", answer, err)
+	}
+}
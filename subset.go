@@ -0,0 +1,407 @@
+package gocaptcha
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/golang/freetype"
+)
+
+var ErrUnsupportedFont = errors.New("unsupported font format, only TrueType outlines can be subset")
+
+// subsetKeepTables 子集化时原样保留的表，这些表中不包含字形编号
+var subsetKeepTables = []string{"OS/2", "cvt ", "fpgm", "gasp", "name", "prep"}
+
+// 复合字形的标志位
+const (
+	compositeArgsAreWords   = 0x0001
+	compositeHaveScale      = 0x0008
+	compositeMoreComponents = 0x0020
+	compositeHaveXYScale    = 0x0040
+	compositeHaveTwoByTwo   = 0x0080
+)
+
+// SubsetFont 对 TrueType 字体做子集化，只保留 charset 中的字符及其依赖的字形.
+// 返回的字体数据可以直接用 freetype.ParseFont 解析，也可以写入 .ttf 文件.
+// 字距调整、OpenType 布局等依赖字形编号的表会被丢弃.
+func SubsetFont(fontBytes []byte, charset []rune) ([]byte, error) {
+	font, err := freetype.ParseFont(fontBytes)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := readFontTables(fontBytes)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "loca", "glyf"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("%w: missing %s table", ErrUnsupportedFont, tag)
+		}
+	}
+
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	locaEntrySize := 2
+	if longLoca {
+		locaEntrySize = 4
+	}
+	if len(tables["loca"]) < (numGlyphs+1)*locaEntrySize {
+		return nil, fmt.Errorf("%w: loca table too short", ErrUnsupportedFont)
+	}
+	glyphData := func(id int) ([]byte, error) {
+		var start, end int
+		if longLoca {
+			start = int(binary.BigEndian.Uint32(tables["loca"][4*id:]))
+			end = int(binary.BigEndian.Uint32(tables["loca"][4*id+4:]))
+		} else {
+			start = 2 * int(binary.BigEndian.Uint16(tables["loca"][2*id:]))
+			end = 2 * int(binary.BigEndian.Uint16(tables["loca"][2*id+2:]))
+		}
+		if start > end || end > len(tables["glyf"]) {
+			return nil, fmt.Errorf("%w: bad loca entry for glyph %d", ErrUnsupportedFont, id)
+		}
+		return tables["glyf"][start:end], nil
+	}
+
+	// 收集需要保留的字形，.notdef 总是保留
+	runeGlyphs := make(map[rune]int)
+	keep := map[int]bool{0: true}
+	queue := []int{0}
+	for _, r := range charset {
+		id := int(font.Index(r))
+		if id == 0 {
+			continue
+		}
+		runeGlyphs[r] = id
+		if !keep[id] {
+			keep[id] = true
+			queue = append(queue, id)
+		}
+	}
+	// 复合字形依赖的子字形也需要保留
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		data, err := glyphData(id)
+		if err != nil {
+			return nil, err
+		}
+		components, err := compositeComponents(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range components {
+			child := int(binary.BigEndian.Uint16(data[c:]))
+			if child >= numGlyphs {
+				return nil, fmt.Errorf("%w: bad component glyph %d", ErrUnsupportedFont, child)
+			}
+			if !keep[child] {
+				keep[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	// 新字形编号按原编号顺序排列
+	oldIDs := make([]int, 0, len(keep))
+	for id := range keep {
+		oldIDs = append(oldIDs, id)
+	}
+	sort.Ints(oldIDs)
+	newIDs := make(map[int]int, len(oldIDs))
+	for i, id := range oldIDs {
+		newIDs[id] = i
+	}
+
+	// glyf、loca 和 hmtx
+	var glyf []byte
+	loca := make([]byte, 4*(len(oldIDs)+1))
+	hmtx := make([]byte, 4*len(oldIDs))
+	for i, id := range oldIDs {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(len(glyf)))
+		data, _ := glyphData(id)
+		data = append([]byte(nil), data...)
+		components, _ := compositeComponents(data)
+		for _, c := range components {
+			child := int(binary.BigEndian.Uint16(data[c:]))
+			binary.BigEndian.PutUint16(data[c:], uint16(newIDs[child]))
+		}
+		glyf = append(glyf, data...)
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+
+		advance, lsb, err := horizontalMetrics(tables["hmtx"], numHMetrics, id)
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(hmtx[4*i:], advance)
+		binary.BigEndian.PutUint16(hmtx[4*i+2:], lsb)
+	}
+	binary.BigEndian.PutUint32(loca[4*len(oldIDs):], uint32(len(glyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	newHhea := append([]byte(nil), hhea...)
+	binary.BigEndian.PutUint16(newHhea[34:], uint16(len(oldIDs)))
+	newMaxp := append([]byte(nil), maxp...)
+	binary.BigEndian.PutUint16(newMaxp[4:], uint16(len(oldIDs)))
+
+	out := map[string][]byte{
+		"cmap": buildCmap(runeGlyphs, newIDs),
+		"glyf": glyf,
+		"head": newHead,
+		"hhea": newHhea,
+		"hmtx": hmtx,
+		"loca": loca,
+		"maxp": newMaxp,
+	}
+	if post := tables["post"]; len(post) >= 16 {
+		// post 表使用 3.0 版本，不保存字形名称
+		newPost := make([]byte, 32)
+		copy(newPost, post[:16])
+		binary.BigEndian.PutUint32(newPost, 0x00030000)
+		out["post"] = newPost
+	}
+	for _, tag := range subsetKeepTables {
+		if tables[tag] != nil {
+			out[tag] = tables[tag]
+		}
+	}
+	return writeFontTables(out), nil
+}
+
+// WriteFontSubset 将字体子集写入 dst，生成的 .ttf 文件可以通过 go:embed 内嵌
+func WriteFontSubset(dst string, fontBytes []byte, charset []rune) error {
+	subset, err := SubsetFont(fontBytes, charset)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, subset, 0644)
+}
+
+// readFontTables 读取 TrueType 字体的表目录
+func readFontTables(fontBytes []byte) (map[string][]byte, error) {
+	if len(fontBytes) < 12 {
+		return nil, ErrUnsupportedFont
+	}
+	if binary.BigEndian.Uint32(fontBytes) != 0x00010000 {
+		// OpenType CFF 字体与字体集合 (TTC) 不支持子集化
+		return nil, ErrUnsupportedFont
+	}
+	n := int(binary.BigEndian.Uint16(fontBytes[4:]))
+	if len(fontBytes) < 12+16*n {
+		return nil, ErrUnsupportedFont
+	}
+	tables := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		record := fontBytes[12+16*i:]
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(fontBytes) {
+			return nil, fmt.Errorf("%w: bad table offset", ErrUnsupportedFont)
+		}
+		tables[string(record[:4])] = fontBytes[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// writeFontTables 按标签顺序写出字体表，并计算校验和
+func writeFontTables(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	header := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(n))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(16*n-searchRange))
+
+	size := len(header)
+	for _, tag := range tags {
+		size += (len(tables[tag]) + 3) &^ 3
+	}
+	out := make([]byte, size)
+	copy(out, header)
+
+	offset := len(header)
+	headOffset := 0
+	for i, tag := range tags {
+		data := tables[tag]
+		record := out[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], tableChecksum(data))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(data)))
+		if tag == "head" {
+			headOffset = offset
+		}
+		copy(out[offset:], data)
+		offset += (len(data) + 3) &^ 3
+	}
+	// head 表中的 checkSumAdjustment 使整个字体的校验和为 0xB1B0AFBA
+	if _, ok := tables["head"]; ok {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-tableChecksum(out))
+	}
+	return out
+}
+
+// tableChecksum 按 32 位大端整数求和，不足 4 字节的部分补 0
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// horizontalMetrics 返回字形的前进宽度和左侧空白
+func horizontalMetrics(hmtx []byte, numHMetrics int, id int) (advance uint16, lsb uint16, err error) {
+	if numHMetrics <= 0 || len(hmtx) < 4*numHMetrics {
+		return 0, 0, fmt.Errorf("%w: bad hmtx table", ErrUnsupportedFont)
+	}
+	if id < numHMetrics {
+		return binary.BigEndian.Uint16(hmtx[4*id:]), binary.BigEndian.Uint16(hmtx[4*id+2:]), nil
+	}
+	advance = binary.BigEndian.Uint16(hmtx[4*(numHMetrics-1):])
+	offset := 4*numHMetrics + 2*(id-numHMetrics)
+	if offset+2 > len(hmtx) {
+		return 0, 0, fmt.Errorf("%w: bad hmtx table", ErrUnsupportedFont)
+	}
+	return advance, binary.BigEndian.Uint16(hmtx[offset:]), nil
+}
+
+// compositeComponents 返回复合字形中每个子字形编号在 data 中的偏移，简单字形返回空
+func compositeComponents(data []byte) ([]int, error) {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil, nil
+	}
+	var offsets []int
+	offset := 10
+	for {
+		if offset+4 > len(data) {
+			return nil, fmt.Errorf("%w: bad composite glyph", ErrUnsupportedFont)
+		}
+		flags := binary.BigEndian.Uint16(data[offset:])
+		offsets = append(offsets, offset+2)
+		offset += 4
+		if flags&compositeArgsAreWords != 0 {
+			offset += 4
+		} else {
+			offset += 2
+		}
+		switch {
+		case flags&compositeHaveScale != 0:
+			offset += 2
+		case flags&compositeHaveXYScale != 0:
+			offset += 4
+		case flags&compositeHaveTwoByTwo != 0:
+			offset += 8
+		}
+		if flags&compositeMoreComponents == 0 {
+			return offsets, nil
+		}
+	}
+}
+
+// buildCmap 生成包含 (3,1) format 4 和 (3,10) format 12 两个子表的 cmap
+func buildCmap(runeGlyphs map[rune]int, newIDs map[int]int) []byte {
+	runes := make([]rune, 0, len(runeGlyphs))
+	for r := range runeGlyphs {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	// 将编码和字形编号都连续的字符合并为一段
+	type group struct {
+		start, end rune
+		glyph      int
+	}
+	var groups []group
+	for _, r := range runes {
+		glyph := newIDs[runeGlyphs[r]]
+		if n := len(groups); n > 0 && groups[n-1].end+1 == r && groups[n-1].glyph+int(r-groups[n-1].start) == glyph {
+			groups[n-1].end = r
+			continue
+		}
+		groups = append(groups, group{start: r, end: r, glyph: glyph})
+	}
+
+	// format 4 只能表示 BMP 内的字符，末尾需要 0xFFFF 结束段
+	var segments []group
+	for _, g := range groups {
+		if g.start > 0xFFFF {
+			break
+		}
+		if g.end > 0xFFFE {
+			g.end = 0xFFFE
+		}
+		segments = append(segments, g)
+	}
+	segments = append(segments, group{start: 0xFFFF, end: 0xFFFF, glyph: 0})
+	segCount := len(segments)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	searchRange := 2 << entrySelector
+
+	format4 := make([]byte, 16+8*segCount)
+	binary.BigEndian.PutUint16(format4, 4)
+	binary.BigEndian.PutUint16(format4[2:], uint16(len(format4)))
+	binary.BigEndian.PutUint16(format4[6:], uint16(2*segCount))
+	binary.BigEndian.PutUint16(format4[8:], uint16(searchRange))
+	binary.BigEndian.PutUint16(format4[10:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(format4[12:], uint16(2*segCount-searchRange))
+	for i, s := range segments {
+		binary.BigEndian.PutUint16(format4[14+2*i:], uint16(s.end))
+		binary.BigEndian.PutUint16(format4[16+2*segCount+2*i:], uint16(s.start))
+		delta := uint16(s.glyph - int(s.start))
+		if s.start == 0xFFFF {
+			delta = 1
+		}
+		binary.BigEndian.PutUint16(format4[16+4*segCount+2*i:], delta)
+		// idRangeOffset 全部为 0
+	}
+
+	format12 := make([]byte, 16+12*len(groups))
+	binary.BigEndian.PutUint16(format12, 12)
+	binary.BigEndian.PutUint32(format12[4:], uint32(len(format12)))
+	binary.BigEndian.PutUint32(format12[12:], uint32(len(groups)))
+	for i, g := range groups {
+		binary.BigEndian.PutUint32(format12[16+12*i:], uint32(g.start))
+		binary.BigEndian.PutUint32(format12[20+12*i:], uint32(g.end))
+		binary.BigEndian.PutUint32(format12[24+12*i:], uint32(g.glyph))
+	}
+
+	header := make([]byte, 4+8*2)
+	binary.BigEndian.PutUint16(header[2:], 2)
+	binary.BigEndian.PutUint16(header[4:], 3)
+	binary.BigEndian.PutUint16(header[6:], 1)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(header)))
+	binary.BigEndian.PutUint16(header[12:], 3)
+	binary.BigEndian.PutUint16(header[14:], 10)
+	binary.BigEndian.PutUint32(header[16:], uint32(len(header)+len(format4)))
+
+	cmap := append(header, format4...)
+	return append(cmap, format12...)
+}
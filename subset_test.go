@@ -0,0 +1,139 @@
+package gocaptcha
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestSubsetFont(t *testing.T) {
+	tests := []struct {
+		name    string
+		font    string
+		charset []rune
+	}{
+		{name: "digits", font: "fonts/Comismsh.ttf", charset: []rune("0123456789")},
+		{name: "text characters", font: "fonts/Esquisito.ttf", charset: TextCharacters},
+		{name: "missing glyphs", font: "fonts/actionj.ttf", charset: []rune("AB山水")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fontBytes, err := embeddedFonts.ReadFile(tt.font)
+			if err != nil {
+				t.Fatal(err)
+			}
+			subset, err := SubsetFont(fontBytes, tt.charset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(subset) >= len(fontBytes) {
+				t.Errorf("SubsetFont() size = %d, original size = %d", len(subset), len(fontBytes))
+			}
+			original, _ := freetype.ParseFont(fontBytes)
+			sub, err := freetype.ParseFont(subset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range tt.charset {
+				if (original.Index(r) == 0) != (sub.Index(r) == 0) {
+					t.Errorf("Index(%q) = %d, original %d", r, sub.Index(r), original.Index(r))
+					continue
+				}
+				if original.Index(r) != 0 && !sameGlyph(original, sub, r) {
+					t.Errorf("glyph %q renders differently after subsetting", r)
+				}
+			}
+		})
+	}
+}
+
+// sameGlyph 比较两个字体渲染同一字符的结果是否完全一致
+func sameGlyph(a, b *truetype.Font, r rune) bool {
+	render := func(f *truetype.Font) (image.Rectangle, []byte, fixed.Int26_6) {
+		face := truetype.NewFace(f, &truetype.Options{Size: 40, DPI: DefaultDPI, Hinting: font.HintingFull})
+		dr, mask, _, advance, ok := face.Glyph(fixed.P(10, 50), r)
+		if !ok {
+			return image.Rectangle{}, nil, 0
+		}
+		alpha := image.NewAlpha(dr)
+		for y := dr.Min.Y; y < dr.Max.Y; y++ {
+			for x := dr.Min.X; x < dr.Max.X; x++ {
+				_, _, _, aa := mask.At(x-dr.Min.X, y-dr.Min.Y).RGBA()
+				alpha.Pix[alpha.PixOffset(x, y)] = uint8(aa >> 8)
+			}
+		}
+		return dr, alpha.Pix, advance
+	}
+	drA, pixA, advA := render(a)
+	drB, pixB, advB := render(b)
+	return drA == drB && advA == advB && string(pixA) == string(pixB)
+}
+
+func TestSubsetFont_Unsupported(t *testing.T) {
+	if _, err := SubsetFont([]byte("not a font"), []rune("A")); err == nil {
+		t.Errorf("SubsetFont() should fail on invalid data")
+	}
+	fontBytes, _ := embeddedFonts.ReadFile("fonts/Comismsh.ttf")
+	otto := append([]byte("OTTO"), fontBytes[4:]...)
+	if _, err := readFontTables(otto); !errors.Is(err, ErrUnsupportedFont) {
+		t.Errorf("readFontTables() error = %v, want %v", err, ErrUnsupportedFont)
+	}
+}
+
+func TestWriteFontSubset(t *testing.T) {
+	fontBytes, _ := embeddedFonts.ReadFile("fonts/Comismsh.ttf")
+	dst := filepath.Join(t.TempDir(), "subset.ttf")
+	if err := WriteFontSubset(dst, fontBytes, []rune("ABC")); err != nil {
+		t.Fatal(err)
+	}
+	fonts, err := NewCustomFontFamily(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fonts.HasGlyph('A') || fonts.HasGlyph('D') {
+		t.Errorf("written subset has unexpected glyphs")
+	}
+
+	// 整个字体的校验和应为 0xB1B0AFBA
+	written, _ := os.ReadFile(dst)
+	if sum := tableChecksum(written); sum != 0xB1B0AFBA {
+		t.Errorf("font checksum = %#x, want %#x", sum, 0xB1B0AFBA)
+	}
+}
+
+func TestFontFamily_AddFontSubset_Memory(t *testing.T) {
+	fontBytes, _ := embeddedFonts.ReadFile("fonts/Esquisito.ttf")
+	fontFile := filepath.Join(t.TempDir(), "Esquisito.ttf")
+	if err := os.WriteFile(fontFile, fontBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 统计加载字体后常驻的堆内存
+	measure := func(add func(*FontFamily) error) int64 {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		fonts, _ := NewCustomFontFamily()
+		if err := add(fonts); err != nil {
+			t.Fatal(err)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(fonts)
+		return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	}
+	full := measure(func(f *FontFamily) error { return f.AddFont(fontFile) })
+	subset := measure(func(f *FontFamily) error { return f.AddFontSubset(fontFile, []rune("0123456789")) })
+	t.Logf("full font: %d bytes, subset: %d bytes", full, subset)
+	if subset >= full {
+		t.Errorf("subset font uses %d bytes, full font uses %d bytes", subset, full)
+	}
+}
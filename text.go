@@ -3,12 +3,15 @@ package gocaptcha
 import (
 	"errors"
 	"image"
+	"image/color"
 	"image/draw"
 	"math"
 	"math/rand"
 	"time"
+	"unicode"
 
 	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
 )
 
@@ -23,11 +26,13 @@ type TextDrawer interface {
 }
 
 type textDrawer struct {
-	dpi float64
-	r   *rand.Rand
+	dpi   float64
+	r     *rand.Rand
+	fonts *FontFamily
 }
 
 // DrawString draws a string on the canvas.
+// Glyphs are composited from masks in DefaultGlyphCache.
 func (t *textDrawer) DrawString(canvas draw.Image, text string) error {
 	if len(text) == 0 {
 		return ErrNilText
@@ -35,37 +40,31 @@ func (t *textDrawer) DrawString(canvas draw.Image, text string) error {
 	if canvas == nil {
 		return ErrNilCanvas
 	}
-	c := freetype.NewContext()
 	if t.dpi <= 0 {
 		t.dpi = 72
 	}
-	c.SetDPI(t.dpi)
-	c.SetClip(canvas.Bounds())
-	c.SetDst(canvas)
-	c.SetHinting(font.HintingFull)
 
-	fontWidth := canvas.Bounds().Dx() / len(text)
+	runes := []rune(text)
+	fontWidth := canvas.Bounds().Dx() / len(runes)
 
-	for i, s := range text {
+	var textColor color.RGBA
+	src := image.NewUniform(&textColor)
+	for i, s := range runes {
 
 		fontSize := float64(canvas.Bounds().Dy()) / (1 + float64(t.r.Intn(7))/float64(9))
 
-		c.SetSrc(image.NewUniform(RandDeepColor()))
-		c.SetFontSize(fontSize)
-		f, err := DefaultFontFamily.Random()
+		textColor = RandDeepColor()
+		f, err := fontFamilyOrDefault(t.fonts).RandomFor(s)
 
 		if err != nil {
 			return err
 		}
-		c.SetFont(f)
 
 		x := (fontWidth)*i + (fontWidth)/int(fontSize)
 
 		y := 5 + t.r.Intn(canvas.Bounds().Dy()/2) + int(fontSize/2)
 
-		pt := freetype.Pt(x, y)
-
-		_, err = c.DrawString(string(s), pt)
+		_, err = DefaultGlyphCache.drawRune(canvas, f, fontSize, t.dpi, image.Pt(x, y), s, src)
 		if err != nil {
 			return err
 		}
@@ -86,9 +85,14 @@ type twistTextDrawer struct {
 	r         *rand.Rand
 	amplitude float64
 	frequency float64
+	// colorOf 返回第 i 个字符的颜色，为空时使用随机深色
+	colorOf func(i int) color.Color
+	// fonts 使用的字体集，为空时使用 DefaultFontFamily
+	fonts *FontFamily
 }
 
 // DrawString draws a string on the canvas.
+// Glyphs are composited from masks in DefaultGlyphCache, then the twist is applied.
 func (t *twistTextDrawer) DrawString(canvas draw.Image, text string) error {
 	if len(text) == 0 {
 		return ErrNilText
@@ -101,21 +105,17 @@ func (t *twistTextDrawer) DrawString(canvas draw.Image, text string) error {
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	// 创建一个新的画布用于存储扭曲后的图像
-	textCanvas := image.NewRGBA(bounds)
-	draw.Draw(textCanvas, textCanvas.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	// 从池中取出透明画布用于存储扭曲前的文字
+	textCanvas := getRGBA(bounds)
+	defer putRGBA(textCanvas)
 
-	c := freetype.NewContext()
 	if t.dpi <= 0 {
 		t.dpi = 72
 	}
-	c.SetDPI(t.dpi)
-	c.SetClip(bounds)
-	c.SetDst(textCanvas)
-	c.SetHinting(font.HintingFull)
 
 	// 计算每个字符的最大宽度，预留边距
-	fontWidth := (width - 20) / len(text) // 左右各预留10像素边距
+	runes := []rune(text)
+	fontWidth := (width - 20) / len(runes) // 左右各预留10像素边距
 
 	// 计算字体大小范围
 	maxFontSize := float64(height) * 0.8 // 使用80%的高度作为最大字体大小
@@ -127,7 +127,10 @@ func (t *twistTextDrawer) DrawString(canvas draw.Image, text string) error {
 		minFontSize = float64(fontWidth) * 0.9 // 使用90%的字符宽度作为最小值
 	}
 
-	for i, s := range text {
+	// 所有字符共用一个颜色源，未指定 colorOf 时只更新 deepColor
+	var deepColor color.RGBA
+	src := &image.Uniform{}
+	for i, s := range runes {
 		// 基准字体大小设置为最小字体大小
 		baseFontSize := minFontSize
 		// 只允许向上浮动，不允许比最小值更小
@@ -135,14 +138,21 @@ func (t *twistTextDrawer) DrawString(canvas draw.Image, text string) error {
 		if fontSize > maxFontSize {
 			fontSize = maxFontSize
 		}
+		// 汉字等全角字符是方形的，宽度约等于字号，不能超过字符宽度
+		if isFullWidth(s) && fontSize > float64(fontWidth)*0.95 {
+			fontSize = float64(fontWidth) * 0.95
+		}
 
-		c.SetSrc(image.NewUniform(RandDeepColor()))
-		c.SetFontSize(fontSize)
-		f, err := DefaultFontFamily.Random()
+		if t.colorOf != nil {
+			src.C = t.colorOf(i)
+		} else {
+			deepColor = RandDeepColor()
+			src.C = &deepColor
+		}
+		f, err := fontFamilyOrDefault(t.fonts).RandomFor(s)
 		if err != nil {
 			return err
 		}
-		c.SetFont(f)
 
 		// 计算文字位置
 		x := 10 + fontWidth*i + (fontWidth-int(fontSize))/2 // 居中对齐
@@ -154,8 +164,7 @@ func (t *twistTextDrawer) DrawString(canvas draw.Image, text string) error {
 		}
 		y := baseY + t.r.Intn(2*maxOffset+1) - maxOffset // 在允许范围内随机偏移
 
-		pt := freetype.Pt(x, y)
-		_, err = c.DrawString(string(s), pt)
+		_, err = DefaultGlyphCache.drawRune(textCanvas, f, fontSize, t.dpi, image.Pt(x, y), s, src)
 		if err != nil {
 			return err
 		}
@@ -165,35 +174,248 @@ func (t *twistTextDrawer) DrawString(canvas draw.Image, text string) error {
 }
 
 func (t *twistTextDrawer) twistEffect(src image.Image, dst draw.Image) error {
+	return twistEffect(src, dst, t.amplitude, t.frequency)
+}
+
+// twistEffect 将 src 中不透明的像素按正弦波水平偏移后写入 dst.
+// src 和 dst 为 *image.NRGBA 或 *image.RGBA 时直接读写 Pix.
+func twistEffect(src image.Image, dst draw.Image, amplitude float64, frequency float64) error {
 	width := src.Bounds().Dx()
 	height := src.Bounds().Dy()
 
-	// 遍历源图像像素
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// 计算扭曲后的坐标
-			dx := int(t.amplitude * math.Sin(t.frequency*float64(y)))
-			newX := x + dx
-			newY := y
-
-			// 如果新坐标在目标图像范围内，设置像素
-			if newX >= 0 && newX < width && newY >= 0 && newY < height {
-				_, _, _, a := src.At(x, y).RGBA()
-				if a != 0 {
-					dst.Set(newX, newY, src.At(x, y))
+	// 相同类型的图片直接复制像素，与 dst.Set(src.At()) 的结果一致
+	var srcPix, dstPix []uint8
+	var srcStride, dstStride int
+	switch s := src.(type) {
+	case *image.NRGBA:
+		if d, ok := dst.(*image.NRGBA); ok && s.Rect.Min == (image.Point{}) && d.Rect.Min == (image.Point{}) {
+			srcPix, srcStride, dstPix, dstStride = s.Pix, s.Stride, d.Pix, d.Stride
+		}
+	case *image.RGBA:
+		if d, ok := dst.(*image.RGBA); ok && s.Rect.Min == (image.Point{}) && d.Rect.Min == (image.Point{}) {
+			srcPix, srcStride, dstPix, dstStride = s.Pix, s.Stride, d.Pix, d.Stride
+		}
+	}
+	dstWidth, dstHeight := dst.Bounds().Max.X, dst.Bounds().Max.Y
+	// 类型不同但都可以直接读写 Pix 时，按预乘颜色转换
+	mixed := srcPix == nil && isPixImage(src) && isPixImage(dst)
+
+	// 每一行只写入 dst 的同一行，直接读写 Pix 时可以按行分段并行
+	twistRows := func(y0, y1 int) error {
+		for y := y0; y < y1; y++ {
+			// 计算扭曲后的坐标，同一行的偏移量相同
+			dx := int(amplitude * math.Sin(frequency*float64(y)))
+			for x := 0; x < width; x++ {
+				newX := x + dx
+				if newX < 0 || newX >= width {
+					continue
+				}
+				if srcPix != nil {
+					if y >= dstHeight || newX >= dstWidth {
+						continue
+					}
+					si := y*srcStride + x*4
+					if srcPix[si+3] != 0 {
+						di := y*dstStride + newX*4
+						copy(dstPix[di:di+4], srcPix[si:si+4])
+					}
+					continue
+				}
+				if mixed {
+					if r, g, b, a := premultipliedAt(src, x, y); a != 0 {
+						setPremultiplied(dst, newX, y, r, g, b, a)
+					}
+					continue
+				}
+				c := src.At(x, y)
+				if _, _, _, a := c.RGBA(); a != 0 {
+					dst.Set(newX, y, c)
 				}
 			}
 		}
+		return nil
 	}
-	return nil
+	if srcPix == nil && !mixed {
+		// draw.Image 的 Set 不一定可以并发调用
+		return twistRows(0, height)
+	}
+	return parallelBands(height, minParallelRows, twistRows)
 }
 
 // NewTwistTextDrawer returns a new text drawer with twist effect.
 func NewTwistTextDrawer(dpi float64, amplitude float64, frequency float64) TextDrawer {
+	return NewTwistTextDrawerWithFonts(nil, dpi, amplitude, frequency)
+}
+
+// NewTwistTextDrawerWithFonts returns a new text drawer with twist effect that
+// draws with the given font family, nil means DefaultFontFamily.
+func NewTwistTextDrawerWithFonts(fonts *FontFamily, dpi float64, amplitude float64, frequency float64) TextDrawer {
 	return &twistTextDrawer{
 		dpi:       dpi,
 		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
 		amplitude: amplitude,
 		frequency: frequency,
+		fonts:     fonts,
+	}
+}
+
+// isFullWidth reports whether r is a square full-width glyph such as Hanzi.
+func isFullWidth(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0xFF01 && r <= 0xFF60) || (r >= 0x3000 && r <= 0x303F)
+}
+
+// fontFamilyOrDefault returns fonts, or DefaultFontFamily when fonts is nil.
+func fontFamilyOrDefault(fonts *FontFamily) *FontFamily {
+	if fonts == nil {
+		return DefaultFontFamily
+	}
+	return fonts
+}
+
+// GlyphTransform is a transform applied to a single glyph.
+type GlyphTransform int
+
+const (
+	// GlyphNormal draws the glyph as is.
+	GlyphNormal GlyphTransform = iota
+	// GlyphMirror mirrors the glyph horizontally.
+	GlyphMirror
+	// GlyphRotate180 rotates the glyph by 180 degrees.
+	GlyphRotate180
+)
+
+// GlyphDrawer is a text drawer that renders every glyph in its own cell.
+type GlyphDrawer interface {
+	TextDrawer
+	// DrawGlyphs draws text with transforms[i] applied to the i-th glyph,
+	// and returns the cell occupied by each glyph.
+	DrawGlyphs(canvas draw.Image, text string, transforms []GlyphTransform) ([]image.Rectangle, error)
+}
+
+type glyphDrawer struct {
+	dpi       float64
+	r         *rand.Rand
+	amplitude float64
+	frequency float64
+}
+
+// DrawString draws a string on the canvas.
+func (g *glyphDrawer) DrawString(canvas draw.Image, text string) error {
+	_, err := g.DrawGlyphs(canvas, text, nil)
+	return err
+}
+
+// DrawGlyphs draws every glyph centered in its own cell with a random font,
+// size and twist, then applies the glyph transform.
+func (g *glyphDrawer) DrawGlyphs(canvas draw.Image, text string, transforms []GlyphTransform) ([]image.Rectangle, error) {
+	if len(text) == 0 {
+		return nil, ErrNilText
+	}
+	if canvas == nil {
+		return nil, ErrNilCanvas
+	}
+	if g.dpi <= 0 {
+		g.dpi = 72
+	}
+
+	runes := []rune(text)
+	bounds := canvas.Bounds()
+	cellWidth := bounds.Dx() / len(runes)
+	cellHeight := bounds.Dy()
+	boxes := make([]image.Rectangle, len(runes))
+
+	for i, s := range runes {
+		cell := image.Rect(0, 0, cellWidth, cellHeight)
+		boxes[i] = cell.Add(image.Pt(bounds.Min.X+cellWidth*i, bounds.Min.Y))
+
+		f, err := DefaultFontFamily.RandomFor(s)
+		if err != nil {
+			return nil, err
+		}
+		fontSize := float64(min(cellWidth, cellHeight)) * (0.6 + float64(g.r.Intn(15))/100.0)
+
+		// Center the glyph inside the cell so that transforms keep it in place.
+		face := truetype.NewFace(f, &truetype.Options{Size: fontSize, DPI: g.dpi, Hinting: font.HintingFull})
+		glyphBounds, _ := font.BoundString(face, string(s))
+		glyphWidth := (glyphBounds.Max.X - glyphBounds.Min.X).Round()
+		glyphHeight := (glyphBounds.Max.Y - glyphBounds.Min.Y).Round()
+		x := (cellWidth-glyphWidth)/2 - glyphBounds.Min.X.Round()
+		y := (cellHeight-glyphHeight)/2 - glyphBounds.Min.Y.Round()
+
+		glyphColor := RandDeepColor()
+		glyphColor.A = 255
+
+		layer := getRGBA(cell)
+		c := freetype.NewContext()
+		c.SetDPI(g.dpi)
+		c.SetClip(cell)
+		c.SetDst(layer)
+		c.SetHinting(font.HintingFull)
+		c.SetSrc(image.NewUniform(glyphColor))
+		c.SetFontSize(fontSize)
+		c.SetFont(f)
+		if _, err = c.DrawString(string(s), freetype.Pt(x, y)); err != nil {
+			putRGBA(layer)
+			return nil, err
+		}
+
+		transform := GlyphNormal
+		if i < len(transforms) {
+			transform = transforms[i]
+		}
+		transformed := transformGlyph(layer, transform)
+
+		twisted := getRGBA(cell)
+		amplitude := g.amplitude * g.r.Float64()
+		frequency := g.frequency * (0.5 + g.r.Float64())
+		err = twistEffect(transformed, twisted, amplitude, frequency)
+		if err == nil {
+			draw.Draw(canvas, boxes[i], twisted, image.Point{}, draw.Over)
+		}
+		putRGBA(twisted)
+		if transformed != layer {
+			putRGBA(transformed)
+		}
+		putRGBA(layer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return boxes, nil
+}
+
+// transformGlyph returns a transformed copy of the glyph layer taken from the pool,
+// or src itself for GlyphNormal.
+func transformGlyph(src *image.RGBA, transform GlyphTransform) *image.RGBA {
+	if transform == GlyphNormal {
+		return src
+	}
+	bounds := src.Bounds()
+	dst := getRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			nx, ny := x, y
+			switch transform {
+			case GlyphMirror:
+				nx = bounds.Max.X - 1 - (x - bounds.Min.X)
+			case GlyphRotate180:
+				nx = bounds.Max.X - 1 - (x - bounds.Min.X)
+				ny = bounds.Max.Y - 1 - (y - bounds.Min.Y)
+			}
+			dst.SetRGBA(nx, ny, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// NewGlyphDrawer returns a new glyph drawer, the twist of every glyph is
+// randomized up to the given amplitude and frequency.
+func NewGlyphDrawer(dpi float64, amplitude float64, frequency float64) GlyphDrawer {
+	return &glyphDrawer{
+		dpi:       dpi,
+		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		amplitude: amplitude,
+		frequency: frequency,
 	}
 }
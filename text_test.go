@@ -2,6 +2,7 @@ package gocaptcha
 
 import (
 	"image"
+	"image/color"
 	"image/draw"
 	"math/rand"
 	"testing"
@@ -110,3 +111,79 @@ func Test_twistTextDrawer_DrawString(t *testing.T) {
 		})
 	}
 }
+
+func Test_glyphDrawer_DrawGlyphs(t *testing.T) {
+	type args struct {
+		canvas     draw.Image
+		text       string
+		transforms []GlyphTransform
+	}
+	tests := []struct {
+		name    string
+		t       GlyphDrawer
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Successful DrawGlyphs",
+			t:    NewGlyphDrawer(DefaultDPI, DefaultAmplitude, DefaultFrequency),
+			args: args{
+				canvas:     image.NewRGBA(image.Rect(0, 0, 200, 50)),
+				text:       "ABCD",
+				transforms: []GlyphTransform{GlyphNormal, GlyphMirror, GlyphRotate180},
+			},
+			wantErr: false,
+		},
+		{
+			name: "DrawGlyphs with empty text",
+			t:    NewGlyphDrawer(DefaultDPI, 0, 0),
+			args: args{
+				canvas: image.NewRGBA(image.Rect(0, 0, 200, 50)),
+				text:   "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "DrawGlyphs with nil canvas",
+			t:    NewGlyphDrawer(DefaultDPI, 0, 0),
+			args: args{
+				canvas: nil,
+				text:   "ABCD",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes, err := tt.t.DrawGlyphs(tt.args.canvas, tt.args.text, tt.args.transforms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("glyphDrawer.DrawGlyphs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(boxes) != len([]rune(tt.args.text)) {
+				t.Errorf("glyphDrawer.DrawGlyphs() len(boxes) = %d, want %d", len(boxes), len([]rune(tt.args.text)))
+			}
+		})
+	}
+}
+
+func Test_transformGlyph(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	tests := []struct {
+		name      string
+		transform GlyphTransform
+		want      image.Point
+	}{
+		{name: "normal", transform: GlyphNormal, want: image.Pt(0, 0)},
+		{name: "mirror", transform: GlyphMirror, want: image.Pt(2, 0)},
+		{name: "rotate 180", transform: GlyphRotate180, want: image.Pt(2, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := transformGlyph(src, tt.transform)
+			if got := dst.RGBAAt(tt.want.X, tt.want.Y); got.R != 255 {
+				t.Errorf("transformGlyph() pixel at %v = %v, want red", tt.want, got)
+			}
+		})
+	}
+}
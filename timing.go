@@ -0,0 +1,118 @@
+package gocaptcha
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimingSamples 判断解题时间是否异常时使用的最近样本数
+	DefaultTimingSamples = 5
+	// DefaultTimingVariation 解题时间的变异系数（标准差/平均值）低于该值时视为异常
+	DefaultTimingVariation = 0.05
+	// timingHistoryTTL 客户端解题记录的保留时间
+	timingHistoryTTL = time.Hour
+)
+
+// SolveTiming 一次校验的耗时信息，通过 Verifier.OnTiming 交给风控系统
+type SolveTiming struct {
+	// Client 客户端 key
+	Client string
+	// ID 验证码 ID
+	ID string
+	// Issued 验证码的生成时间
+	Issued time.Time
+	// SolveTime 从生成到提交答案的耗时
+	SolveTime time.Duration
+	// Success 答案是否正确
+	Success bool
+	// TooFast 提交早于 Verifier.MinSolveTime
+	TooFast bool
+	// Suspicious 该客户端最近的解题时间过于一致
+	Suspicious bool
+}
+
+type timingHistory struct {
+	samples []time.Duration
+	last    time.Time
+}
+
+// TimingDetector 记录每个客户端最近的解题时间，人类的耗时波动较大，
+// 而脚本的耗时往往非常接近.
+type TimingDetector struct {
+	// Samples 参与判断的最近样本数，为 0 时使用 DefaultTimingSamples
+	Samples int
+	// MaxVariation 变异系数低于该值时视为异常，为 0 时使用 DefaultTimingVariation
+	MaxVariation float64
+
+	mu        sync.Mutex
+	history   map[string]*timingHistory
+	lastSweep time.Time
+}
+
+// NewTimingDetector 使用默认参数创建检测器
+func NewTimingDetector() *TimingDetector {
+	return &TimingDetector{}
+}
+
+// Observe 记录 client 的一次解题时间，返回最近的解题时间是否过于一致
+func (d *TimingDetector) Observe(client string, solveTime time.Duration) (suspicious bool) {
+	samples := d.Samples
+	if samples <= 1 {
+		samples = DefaultTimingSamples
+	}
+	maxVariation := d.MaxVariation
+	if maxVariation <= 0 {
+		maxVariation = DefaultTimingVariation
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if d.history == nil {
+		d.history = make(map[string]*timingHistory)
+		d.lastSweep = now
+	}
+	if now.Sub(d.lastSweep) > timingHistoryTTL {
+		for k, h := range d.history {
+			if now.Sub(h.last) > timingHistoryTTL {
+				delete(d.history, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	h, ok := d.history[client]
+	if !ok {
+		h = &timingHistory{}
+		d.history[client] = h
+	}
+	h.samples = append(h.samples, solveTime)
+	if len(h.samples) > samples {
+		h.samples = h.samples[len(h.samples)-samples:]
+	}
+	h.last = now
+	if len(h.samples) < samples {
+		return false
+	}
+	return variation(h.samples) < maxVariation
+}
+
+// variation 返回样本的变异系数
+func variation(samples []time.Duration) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s)
+	}
+	mean := sum / float64(len(samples))
+	if mean <= 0 {
+		return 0
+	}
+	var sq float64
+	for _, s := range samples {
+		sq += (float64(s) - mean) * (float64(s) - mean)
+	}
+	return math.Sqrt(sq/float64(len(samples))) / mean
+}
@@ -0,0 +1,86 @@
+package gocaptcha
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimingDetector(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    bool
+	}{
+		{name: "too few samples", samples: []time.Duration{time.Second, time.Second}, want: false},
+		{name: "human", samples: []time.Duration{3 * time.Second, 5 * time.Second, 2 * time.Second, 8 * time.Second, 4 * time.Second}, want: false},
+		{name: "scripted", samples: []time.Duration{2000, 2010, 1990, 2005, 1995}, want: true},
+		{name: "only recent samples count", samples: []time.Duration{9 * time.Second, 2000, 2010, 1990, 2005, 1995}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewTimingDetector()
+			var got bool
+			for _, s := range tt.samples {
+				got = d.Observe("client", s)
+			}
+			if got != tt.want {
+				t.Errorf("Observe() = %v, want %v", got, tt.want)
+			}
+			if d.Observe("other", tt.samples[0]) {
+				t.Error("Observe() flagged an unrelated client")
+			}
+		})
+	}
+}
+
+func TestVerifierMinSolveTime(t *testing.T) {
+	var timings []SolveTiming
+	v := &Verifier{
+		Store:        NewMemoryStore(DefaultExpiration),
+		MaxAttempts:  3,
+		MinSolveTime: 30 * time.Millisecond,
+		OnTiming:     func(t SolveTiming) { timings = append(timings, t) },
+	}
+	id, _ := v.Issue("client", "abcd")
+	if err := v.Verify("client", id, "abcd"); !errors.Is(err, ErrTooFast) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrTooFast)
+	}
+	// 提交过快的验证码直接作废
+	if err := v.Verify("client", id, "abcd"); !errors.Is(err, ErrCaptchaNotFound) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrCaptchaNotFound)
+	}
+
+	id, _ = v.Issue("client", "abcd")
+	time.Sleep(40 * time.Millisecond)
+	if err := v.Verify("client", id, "abcd"); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	if len(timings) != 2 {
+		t.Fatalf("OnTiming called %d times, want 2", len(timings))
+	}
+	if !timings[0].TooFast || timings[0].Success || timings[0].Client != "client" {
+		t.Errorf("first timing = %+v", timings[0])
+	}
+	if timings[1].TooFast || !timings[1].Success || timings[1].SolveTime < 40*time.Millisecond || timings[1].ID != id {
+		t.Errorf("second timing = %+v", timings[1])
+	}
+}
+
+func TestVerifierSuspiciousTiming(t *testing.T) {
+	var last SolveTiming
+	v := &Verifier{
+		Store:    NewMemoryStore(DefaultExpiration),
+		Timing:   &TimingDetector{Samples: 3, MaxVariation: 0.5},
+		OnTiming: func(t SolveTiming) { last = t },
+	}
+	for i := 0; i < 3; i++ {
+		id, _ := v.Issue("bot", "abcd")
+		time.Sleep(10 * time.Millisecond)
+		v.Verify("bot", id, "abcd")
+	}
+	if !last.Suspicious || !last.Success {
+		t.Errorf("last timing = %+v, want suspicious success", last)
+	}
+}
@@ -35,18 +35,16 @@ func RandLightColor() color.RGBA {
 
 // RandColor 生成随机颜色.
 func RandColor() color.RGBA {
-	red := rand.Intn(255)
-	green := rand.Intn(255)
-	var blue int
+	return randColorBits(uint32(rand.Uint64()))
+}
 
+// randColorBits 使用 v 的随机位生成 RandColor 的颜色，红、绿分量各取 16 位映射到 [0, 255).
+// 绘制器用自己的随机源一次生成多个颜色，避免争用全局随机源的锁.
+func randColorBits(v uint32) color.RGBA {
+	red := int(v&0xffff) * 255 >> 16
+	green := int(v>>16) * 255 >> 16
 	// Calculate blue value based on the sum of red and green
-	sum := red + green
-	if sum > 400 {
-		blue = 0
-	} else {
-		blueTemp := 400 - sum
-		blue = int(max(0, min(255, float64(blueTemp))))
-	}
+	blue := max(0, min(255, 400-red-green))
 	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: 255}
 }
 
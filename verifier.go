@@ -0,0 +1,261 @@
+package gocaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAttempts 默认每个验证码允许的尝试次数
+const DefaultMaxAttempts = 1
+
+var (
+	ErrWrongAnswer     = errors.New("wrong captcha answer")
+	ErrTooManyAttempts = errors.New("too many captcha attempts")
+	ErrTooFast         = errors.New("captcha answered too fast")
+)
+
+// verifyRecord Verifier 保存到 store 中的记录
+type verifyRecord struct {
+	Type     string `json:"type,omitempty"`
+	Answer   string `json:"answer"`
+	Issued   int64  `json:"issued"`
+	Attempts int    `json:"attempts"`
+}
+
+// parseVerifyRecord 解析 Verifier 保存的记录，不是 Verifier 的记录时返回 false
+func parseVerifyRecord(value string) (verifyRecord, bool) {
+	var record verifyRecord
+	if !strings.HasPrefix(value, "{") || json.Unmarshal([]byte(value), &record) != nil || record.Issued == 0 {
+		return verifyRecord{}, false
+	}
+	return record, true
+}
+
+// Verifier 带尝试次数限制和客户端限流的验证码校验器.
+// 验证码答错 MaxAttempts 次后作废，生成和校验都按客户端 key 消耗 Limiter 的配额.
+type Verifier struct {
+	// Store 保存验证码记录的存储，为空时使用 DefaultStore
+	Store Store
+	// MaxAttempts 每个验证码允许的尝试次数，为 0 时使用 DefaultMaxAttempts
+	MaxAttempts int
+	// Expiration 验证码有效期，为 0 时使用 DefaultExpiration
+	Expiration time.Duration
+	// Limiter 按客户端限制生成和校验的频率，为空时不限制
+	Limiter Limiter
+	// Compare 比较文本答案，为空时忽略首尾空白并不区分大小写.
+	// 通过 IssueChallenge 生成的题目使用其类型的 ChallengeGenerator.Check 校验.
+	Compare func(want string, answer string) bool
+	// Registry 题目类型的注册表，为空时使用 DefaultChallengeRegistry
+	Registry *ChallengeRegistry
+	// MinSolveTime 人类解题所需的最短时间，更早的提交返回 ErrTooFast 并作废验证码
+	MinSolveTime time.Duration
+	// Timing 检测客户端解题时间是否过于一致，为空时不检测
+	Timing *TimingDetector
+	// OnTiming 每次校验后调用，可将耗时信息交给风控系统
+	OnTiming func(SolveTiming)
+}
+
+// NewVerifier 创建校验器
+func NewVerifier(store Store, maxAttempts int, limiter Limiter) *Verifier {
+	return &Verifier{Store: store, MaxAttempts: maxAttempts, Limiter: limiter}
+}
+
+func (v *Verifier) store() Store {
+	if v.Store == nil {
+		return DefaultStore
+	}
+	return v.Store
+}
+
+// Issue 为 client 保存答案并返回验证码 ID
+func (v *Verifier) Issue(client string, answer string) (id string, err error) {
+	if err = allow(v.Limiter, client); err != nil {
+		return "", err
+	}
+	return v.issue(verifyRecord{Answer: answer})
+}
+
+func (v *Verifier) issue(record verifyRecord) (string, error) {
+	if record.Issued == 0 {
+		record.Issued = time.Now().UnixNano()
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return Issue(v.store(), string(b))
+}
+
+func (v *Verifier) registry() *ChallengeRegistry {
+	if v.Registry == nil {
+		return DefaultChallengeRegistry
+	}
+	return v.Registry
+}
+
+func (v *Verifier) expiration() time.Duration {
+	if v.Expiration <= 0 {
+		return DefaultExpiration
+	}
+	return v.Expiration
+}
+
+// IssueChallenge 为 client 生成 typeName 类型的题目并保存校验记录
+func (v *Verifier) IssueChallenge(client string, typeName string, opts ChallengeOptions) (*Challenge, error) {
+	return v.IssueChallengeContext(context.Background(), client, typeName, opts)
+}
+
+// IssueChallengeContext 与 IssueChallenge 相同，ctx 取消或超时后返回 ctx.Err()
+func (v *Verifier) IssueChallengeContext(ctx context.Context, client string, typeName string, opts ChallengeOptions) (*Challenge, error) {
+	if err := allow(v.Limiter, client); err != nil {
+		return nil, err
+	}
+	c, err := NewChallengeContext(ctx, v.registry(), typeName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return v.save(c)
+}
+
+// IssuePooled 为 client 从 pool 取出预设 preset 的题目并保存校验记录.
+// pool 与 Verifier 应使用同一个注册表，否则校验时可能找不到题目类型.
+func (v *Verifier) IssuePooled(ctx context.Context, client string, pool *Pool, preset string) (*Challenge, error) {
+	if err := allow(v.Limiter, client); err != nil {
+		return nil, err
+	}
+	c, err := pool.Get(ctx, preset)
+	if err != nil {
+		return nil, err
+	}
+	return v.save(c)
+}
+
+// save 保存题目的校验记录，有效期从保存时开始计算
+func (v *Verifier) save(c *Challenge) (*Challenge, error) {
+	issued := time.Now()
+	id, err := v.issue(verifyRecord{Type: c.Type, Answer: c.record, Issued: issued.UnixNano()})
+	if err != nil {
+		return nil, err
+	}
+	c.ID = id
+	c.Expires = issued.Add(v.expiration())
+	return c, nil
+}
+
+// IssueCaptcha 为 client 生成文本验证码，超出频率限制时不会生成图片
+func (v *Verifier) IssueCaptcha(client string, width, height int, textLength int, difficulty CaptchaDifficulty) (id string, imgBytes []byte, err error) {
+	if err = allow(v.Limiter, client); err != nil {
+		return "", nil, err
+	}
+	text, imgBytes, err := GenerateCaptcha(width, height, textLength, difficulty)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err = v.issue(verifyRecord{Answer: text})
+	if err != nil {
+		return "", nil, err
+	}
+	return id, imgBytes, nil
+}
+
+// Verify 校验 client 提交的答案，正确时返回 nil.
+// 答错时返回 ErrWrongAnswer，用完多次机会后返回 ErrTooManyAttempts 并作废验证码.
+// 只允许一次尝试的验证码答错后同样作废，但返回 ErrWrongAnswer，客户端没有被限流.
+func (v *Verifier) Verify(client string, id string, answer string) error {
+	if err := allow(v.Limiter, client); err != nil {
+		return err
+	}
+	store := v.store()
+	// 先取出并删除记录，避免并发请求共享同一次尝试机会
+	value, err := store.Get(id, true)
+	if err != nil {
+		return err
+	}
+	record, ok := parseVerifyRecord(value)
+	if !ok {
+		return ErrCaptchaNotFound
+	}
+	issued := time.Unix(0, record.Issued)
+	if time.Since(issued) > v.expiration() {
+		return ErrCaptchaNotFound
+	}
+
+	timing := SolveTiming{Client: client, ID: id, Issued: issued, SolveTime: time.Since(issued)}
+	defer func() {
+		if v.OnTiming != nil {
+			v.OnTiming(timing)
+		}
+	}()
+	if timing.SolveTime < v.MinSolveTime {
+		timing.TooFast = true
+		return ErrTooFast
+	}
+
+	compare := v.Compare
+	if compare == nil {
+		compare = equalFoldAnswer
+	}
+	if record.Type != "" {
+		t, ok := v.registry().Lookup(record.Type)
+		if !ok || t.Generator == nil {
+			return ErrUnknownChallengeType
+		}
+		compare = t.Generator.Check
+	}
+	timing.Success = compare(record.Answer, answer)
+	if v.Timing != nil {
+		timing.Suspicious = v.Timing.Observe(client, timing.SolveTime)
+	}
+	if timing.Success {
+		return nil
+	}
+
+	maxAttempts := v.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	record.Attempts++
+	if record.Attempts >= maxAttempts {
+		if maxAttempts == 1 {
+			return ErrWrongAnswer
+		}
+		return ErrTooManyAttempts
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err = store.Set(id, string(b)); err != nil {
+		return err
+	}
+	return ErrWrongAnswer
+}
+
+// WriteError 将校验错误写为 HTTP 响应.
+// 频率限制和尝试次数过多返回 429 并设置 Retry-After，答错、提交过快或验证码不存在返回 400.
+func WriteError(w http.ResponseWriter, err error) {
+	var rateErr *RateLimitError
+	switch {
+	case errors.As(err, &rateErr):
+		seconds := int(math.Ceil(rateErr.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrTooManyAttempts):
+		// 验证码已作废，客户端可以立即重新获取
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrWrongAnswer), errors.Is(err, ErrCaptchaNotFound), errors.Is(err, ErrTooFast):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,97 @@
+package gocaptcha
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifierMaxAttempts(t *testing.T) {
+	v := NewVerifier(NewMemoryStore(DefaultExpiration), 3, nil)
+	id, err := v.Issue("client", "abcd")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	for i, want := range []error{ErrWrongAnswer, ErrWrongAnswer, ErrTooManyAttempts, ErrCaptchaNotFound} {
+		if err := v.Verify("client", id, "zzzz"); !errors.Is(err, want) {
+			t.Errorf("Verify() #%d error = %v, want %v", i, err, want)
+		}
+	}
+
+	id, _ = v.Issue("client", "abcd")
+	if err := v.Verify("client", id, "zzzz"); !errors.Is(err, ErrWrongAnswer) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrWrongAnswer)
+	}
+	if err := v.Verify("client", id, " ABCD "); err != nil {
+		t.Errorf("Verify() with right answer error = %v", err)
+	}
+	if err := v.Verify("client", id, "abcd"); !errors.Is(err, ErrCaptchaNotFound) {
+		t.Errorf("Verify() after success error = %v, want %v", err, ErrCaptchaNotFound)
+	}
+}
+
+func TestVerifierDefaultIsOneShot(t *testing.T) {
+	v := &Verifier{Store: NewMemoryStore(DefaultExpiration)}
+	id, _ := v.Issue("client", "abcd")
+	// 一次答错只是答错，不应被当作尝试次数过多而返回 429
+	if err := v.Verify("client", id, "zzzz"); !errors.Is(err, ErrWrongAnswer) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrWrongAnswer)
+	}
+	if err := v.Verify("client", id, "abcd"); !errors.Is(err, ErrCaptchaNotFound) {
+		t.Errorf("Verify() after wrong answer error = %v, want %v", err, ErrCaptchaNotFound)
+	}
+}
+
+func TestVerifierExpiration(t *testing.T) {
+	v := &Verifier{Store: NewMemoryStore(DefaultExpiration), MaxAttempts: 5, Expiration: 20 * time.Millisecond}
+	id, _ := v.Issue("client", "abcd")
+	v.Verify("client", id, "zzzz")
+	time.Sleep(30 * time.Millisecond)
+	// 答错后重新保存记录不会延长有效期
+	if err := v.Verify("client", id, "abcd"); !errors.Is(err, ErrCaptchaNotFound) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrCaptchaNotFound)
+	}
+}
+
+func TestVerifierRateLimit(t *testing.T) {
+	v := NewVerifier(NewMemoryStore(DefaultExpiration), 5, NewRateLimiter(1, 2))
+	id, imgBytes, err := v.IssueCaptcha("client", 180, 60, 4, CaptchaEasy)
+	if err != nil || len(imgBytes) == 0 {
+		t.Fatalf("IssueCaptcha() error = %v", err)
+	}
+	if err := v.Verify("client", id, "wrong"); !errors.Is(err, ErrWrongAnswer) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrWrongAnswer)
+	}
+	if err := v.Verify("client", id, "wrong"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrRateLimited)
+	}
+	if _, err := v.Issue("other", "abcd"); err != nil {
+		t.Errorf("Issue() for another client error = %v", err)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		code       int
+		retryAfter string
+	}{
+		{name: "rate limited", err: &RateLimitError{RetryAfter: 1500 * time.Millisecond}, code: http.StatusTooManyRequests, retryAfter: "2"},
+		{name: "too many attempts", err: ErrTooManyAttempts, code: http.StatusTooManyRequests, retryAfter: "0"},
+		{name: "wrong answer", err: ErrWrongAnswer, code: http.StatusBadRequest},
+		{name: "not found", err: ErrCaptchaNotFound, code: http.StatusBadRequest},
+		{name: "other", err: errors.New("boom"), code: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, tt.err)
+			if rec.Code != tt.code || rec.Header().Get("Retry-After") != tt.retryAfter {
+				t.Errorf("WriteError() = %d, Retry-After %q, want %d, %q", rec.Code, rec.Header().Get("Retry-After"), tt.code, tt.retryAfter)
+			}
+		})
+	}
+}